@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gnyman/flowdock"
+)
+
+// FlowdockAPI is the part of the Flowdock REST API the bot uses, so that it
+// can be faked in tests
+type FlowdockAPI interface {
+	SendMessage(flowID, threadID, message string) ([]byte, error)
+	SendComment(flowID, messageID, message string) ([]byte, error)
+	EditMessage(org, flow, messageID, message string, tags []string) ([]byte, error)
+	SendPrivateMessage(userID, message string) ([]byte, error)
+}
+
+// restAPI calls the Flowdock REST API with the configured API key
+type restAPI struct{}
+
+// SendMessage sends a message to the thread with threadID in the flow
+func (restAPI) SendMessage(flowID, threadID, message string) ([]byte, error) {
+	return flowdock.SendMessageToFlowWithApiKey(flowdockAPIKey, flowID, threadID, message)
+}
+
+// SendComment comments on the message with messageID in the flow
+func (restAPI) SendComment(flowID, messageID, message string) ([]byte, error) {
+	return flowdock.SendCommentToFlowWithApiKey(flowdockAPIKey, flowID, messageID, message)
+}
+
+// EditMessage edits the message with messageID in the flow, adding tags
+func (restAPI) EditMessage(org, flow, messageID, message string, tags []string) ([]byte, error) {
+	return flowdock.EditMessageInFlowWithApiKey(flowdockAPIKey, org, flow, messageID, message, tags)
+}
+
+// SendPrivateMessage sends a private message to the user with userID
+func (restAPI) SendPrivateMessage(userID, message string) ([]byte, error) {
+	return sendPrivateMessage(flowdockAPIKey, userID, message)
+}
+
+// api is the Flowdock API used to send and edit messages
+var api FlowdockAPI = restAPI{}
+
+// botUserID is the user ID of the bot, whose own messages are not pings
+var botUserID string
+
+// fetchBotUserID returns the user ID the API key belongs to
+func fetchBotUserID(apiKey string) (string, error) {
+	req, err := http.NewRequest("GET", "https://api.flowdock.com/user", nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(apiKey, "BATMAN")
+
+	client := http.Client{}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(user.ID, 10), nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jabbors/flowdock-notifybot/timeexpr"
+)
+
+// awayUntil returns the time the user with nick returns if they are away at
+// now
+func awayUntil(nick string, now time.Time) (time.Time, bool) {
+	until := userSettings.Get(nick).AwayUntil
+	return until, until.After(now)
+}
+
+// returnTime returns when pings to the user with nick that is away until the
+// given time land: 09:00 on their first workday back, within working hours
+func returnTime(nick string, until time.Time) time.Time {
+	calendar := calendarFor(nick)
+	t := calendar.NextWorkdayAt(until, 9, 0)
+	t, _ = calendar.Defer(t)
+	return t
+}
+
+// deferForAway returns t, or the return time of the user with nick if they
+// are away at t. The second return value is the time they are away until,
+// zero if they are not away.
+func deferForAway(nick string, t time.Time) (time.Time, time.Time) {
+	until, away := awayUntil(nick, t)
+	if !away {
+		return t, time.Time{}
+	}
+	return returnTime(nick, until), until
+}
+
+// parseAwayUntil parses the date a user returns, either YYYY-MM-DD or a time
+// expression like "next monday", in location. The user is away until the
+// start of that day.
+func parseAwayUntil(text string, now time.Time, location *time.Location) (time.Time, error) {
+	text = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "until"))
+	if date, err := time.ParseInLocation(dateLayout, text, location); err == nil {
+		return date, nil
+	}
+	if t, _, ok := timeexpr.Parse(text, now.In(location)); ok {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location), nil
+	}
+	return time.Time{}, fmt.Errorf("Unknown date '%s', use e.g. %saway until 2026-11-02", text, prefix)
+}
+
+// setAway marks the user with nick away until the given time, or back if it
+// is zero, and persists the change
+func setAway(nick string, until time.Time) {
+	setting := userSettings.Get(nick)
+	setting.AwayUntil = until
+	userSettings.Set(nick, setting)
+	err := userSettings.Save(settingsStorage)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// awayCommand handles the away command sent by the user with nick and returns
+// the reply to send back. Users mark themselves away with "until <date>" and
+// back with "clear". Admins may do the same for others by giving a nick first,
+// and "list" shows everyone who is away.
+func awayCommand(nick, args string, now time.Time) string {
+	fields := strings.Fields(args)
+	target := nick
+	if len(fields) > 0 && users.Exists(fields[0]) {
+		if !admins[strings.ToLower(nick)] && !strings.EqualFold(fields[0], nick) {
+			return "Only admins can change the away status of others."
+		}
+		target = strings.ToLower(fields[0])
+		fields = fields[1:]
+	}
+	location := locationFor(target)
+
+	switch {
+	case len(fields) == 0:
+		if until, away := awayUntil(target, now); away {
+			return fmt.Sprintf("%s is away until %s.", target, until.In(location).Format("Mon 2 Jan"))
+		}
+		return fmt.Sprintf("%s is not away.", target)
+	case fields[0] == "list":
+		var away []string
+		for user := range userSettings {
+			if until, ok := awayUntil(user, now); ok {
+				away = append(away, fmt.Sprintf("- %s until %s", user, until.In(locationFor(user)).Format("Mon 2 Jan 2006")))
+			}
+		}
+		if len(away) == 0 {
+			return "Nobody is away."
+		}
+		sort.Strings(away)
+		return "Away:\n" + strings.Join(away, "\n")
+	case fields[0] == "clear":
+		setAway(target, time.Time{})
+		log.Printf("%s marked %s back", nick, target)
+		return fmt.Sprintf("%s is no longer away.", target)
+	}
+
+	until, err := parseAwayUntil(strings.Join(fields, " "), now, location)
+	if err != nil {
+		return err.Error()
+	}
+	if !until.After(now) {
+		return "The return date must be in the future."
+	}
+	setAway(target, until)
+	log.Printf("%s marked %s away until %v", nick, target, until)
+	return fmt.Sprintf("%s is away until %s, pings will land %s.", target, until.Format("Mon 2 Jan"), returnTime(target, until).Format("Mon 2 Jan 15:04 MST"))
+}
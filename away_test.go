@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAwayCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "away")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	settingsStorage = filepath.Join(dir, "settings")
+	defaultLocation, _ = time.LoadLocation("Europe/Helsinki")
+	userConfigs = make(map[string]userConfig)
+	userSettings = NewUserSettings()
+	defaultCalendar, _ = NewCalendar(WorkingHours{}, nil)
+	users = Users{"alice": "1", "bob": "2"}
+	admins = map[string]bool{"alice": true}
+	// Saturday
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, defaultLocation)
+
+	awayCommand("bob", "until 2026-11-02", now)
+	until, away := awayUntil("bob", now)
+	if !away || !until.Equal(time.Date(2026, 11, 2, 0, 0, 0, 0, defaultLocation)) {
+		t.Errorf("Wanted bob away until 2026-11-02, got %v %v", away, until)
+	}
+	got, until := deferForAway("bob", now.Add(time.Hour))
+	if want := time.Date(2026, 11, 2, 9, 0, 0, 0, defaultLocation); !got.Equal(want) {
+		t.Errorf("Wanted ping deferred to %v, got %v", want, got)
+	}
+	if got, _ := deferForAway("bob", until.Add(time.Hour)); !got.Equal(until.Add(time.Hour)) {
+		t.Errorf("Wanted ping after the return not deferred, got %v", got)
+	}
+
+	if reply := awayCommand("bob", "alice until 2026-11-02", now); reply != "Only admins can change the away status of others." {
+		t.Errorf("Wanted bob denied, got %q", reply)
+	}
+	awayCommand("alice", "bob clear", now)
+	if _, away := awayUntil("bob", now); away {
+		t.Error("Wanted bob no longer away")
+	}
+	if reply := awayCommand("alice", "until 2026-01-01", now); reply != "The return date must be in the future." {
+		t.Errorf("Wanted past date rejected, got %q", reply)
+	}
+	if reply := awayCommand("alice", "until someday", now); reply == "" {
+		t.Error("Wanted unknown date rejected")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WorkingHours is the daily window, as HH:MM, during which a user wants to
+// receive notifications
+type WorkingHours struct {
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// defaultWorkingDays are used when no working days are given
+var defaultWorkingDays = []string{"mon", "tue", "wed", "thu", "fri"}
+
+// Calendar is the business calendar of a user. It knows which days are
+// workdays and, if Restricted, during which hours notifications may be
+// delivered.
+type Calendar struct {
+	Location   *time.Location
+	Holidays   Holidays
+	Workdays   [7]bool       // by time.Weekday
+	Start      time.Duration // start of working hours since midnight
+	End        time.Duration // end of working hours since midnight
+	Restricted bool          // deliveries outside working hours are deferred
+}
+
+// NewCalendar parses working hours and days into a calendar in UTC without
+// holidays. Missing hours mean the whole day and missing days mean monday to
+// friday. Deliveries are restricted to working hours only if hours are given,
+// working days alone just define the next workday.
+func NewCalendar(hours WorkingHours, days []string) (*Calendar, error) {
+	c := &Calendar{Location: time.UTC, End: 24 * time.Hour}
+	c.Restricted = hours != (WorkingHours{})
+	if hours.Start != "" {
+		hour, min, err := parseClock(hours.Start)
+		if err != nil {
+			return nil, err
+		}
+		c.Start = time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute
+	}
+	if hours.End != "" {
+		hour, min, err := parseClock(hours.End)
+		if err != nil {
+			return nil, err
+		}
+		c.End = time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute
+	}
+	if c.Start >= c.End {
+		return nil, fmt.Errorf("working hours must start before they end, got %s-%s", hours.Start, hours.End)
+	}
+	if len(days) == 0 {
+		days = defaultWorkingDays
+	}
+	for _, day := range days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("unknown working day '%s'", day)
+		}
+		c.Workdays[weekday] = true
+	}
+	return c, nil
+}
+
+// IsWorkday returns true if the date of t, in the location of the calendar,
+// is a working day and not a holiday
+func (c *Calendar) IsWorkday(t time.Time) bool {
+	t = t.In(c.Location)
+	return c.Workdays[t.Weekday()] && !c.Holidays.Contains(t)
+}
+
+// NextWorkdayAt returns the first workday at hour:min after now
+func (c *Calendar) NextWorkdayAt(now time.Time, hour, min int) time.Time {
+	now = now.In(c.Location)
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, min, 0, 0, c.Location)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	// A year is plenty to find a workday, more means there are none at all
+	for i := 0; i < 366 && !c.IsWorkday(next); i++ {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Contains returns true if t is within the working hours of a workday
+func (c *Calendar) Contains(t time.Time) bool {
+	t = t.In(c.Location)
+	hour, min, sec := t.Clock()
+	sinceMidnight := time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+	return c.IsWorkday(t) && sinceMidnight >= c.Start && sinceMidnight < c.End
+}
+
+// Defer returns t if deliveries are not restricted or t is within working
+// hours, otherwise the start of the next working hours after t. The second
+// return value tells if t was deferred.
+func (c *Calendar) Defer(t time.Time) (time.Time, bool) {
+	if !c.Restricted || c.Contains(t) {
+		return t, false
+	}
+	day := t.In(c.Location)
+	for i := 0; i < 366; i++ {
+		start := clockOn(day, c.Start)
+		if start.After(t) && c.IsWorkday(start) {
+			return start, true
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return t, false
+}
+
+// clockOn returns the wall clock time d after midnight on the day of t, which
+// differs from midnight plus d on days with a DST transition
+func clockOn(t time.Time, d time.Duration) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), int(d/time.Hour), int(d%time.Hour/time.Minute), 0, 0, t.Location())
+}
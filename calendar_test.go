@@ -0,0 +1,171 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func testCalendar(t *testing.T, timezone string, hours WorkingHours, days []string) *Calendar {
+	calendar, err := NewCalendar(hours, days)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calendar.Location, err = time.LoadLocation(timezone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return calendar
+}
+
+func TestCalendarNextWorkdayAtWeekBoundaries(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	date := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, helsinki)
+	}
+
+	tests := []struct {
+		name string
+		days []string
+		now  time.Time
+		want time.Time
+	}{
+		{"mon-fri, friday", nil, date(10, 16, 10), date(10, 19, 9)},
+		{"mon-fri, saturday", nil, date(10, 17, 8), date(10, 19, 9)},
+		{"mon-fri, sunday evening", nil, date(10, 18, 23), date(10, 19, 9)},
+		{"mon-fri, monday before nine", nil, date(10, 19, 8), date(10, 19, 9)},
+		{"mon-fri, monday at nine", nil, date(10, 19, 9), date(10, 20, 9)},
+		{"sun-thu, thursday", []string{"sun", "mon", "tue", "wed", "thu"}, date(10, 15, 10), date(10, 18, 9)},
+		{"sun-thu, friday", []string{"sun", "mon", "tue", "wed", "thu"}, date(10, 16, 10), date(10, 18, 9)},
+		{"sun-thu, saturday", []string{"sun", "mon", "tue", "wed", "thu"}, date(10, 17, 8), date(10, 18, 9)},
+		{"mon-thu, thursday", []string{"mon", "tue", "wed", "thu"}, date(10, 15, 10), date(10, 19, 9)},
+		{"mon-thu, wednesday", []string{"mon", "tue", "wed", "thu"}, date(10, 14, 10), date(10, 15, 9)},
+		{"mon-fri, over new year", nil, date(12, 31, 10), time.Date(2027, 1, 1, 9, 0, 0, 0, helsinki)},
+	}
+	for _, test := range tests {
+		calendar := testCalendar(t, "Europe/Helsinki", WorkingHours{}, test.days)
+		got := calendar.NextWorkdayAt(test.now, 9, 0)
+		if !got.Equal(test.want) {
+			t.Errorf("%s: wanted %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestCalendarNextWorkdayAtDST(t *testing.T) {
+	tests := []struct {
+		timezone string
+		now      time.Time
+		want     time.Time
+	}{
+		// Helsinki moves to summer time on sunday 2026-03-29
+		{"Europe/Helsinki", time.Date(2026, 3, 27, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 30, 6, 0, 0, 0, time.UTC)},
+		// and back to winter time on sunday 2026-10-25
+		{"Europe/Helsinki", time.Date(2026, 10, 23, 12, 0, 0, 0, time.UTC), time.Date(2026, 10, 26, 7, 0, 0, 0, time.UTC)},
+		// New York moves to summer time on sunday 2026-03-08
+		{"America/New_York", time.Date(2026, 3, 6, 20, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC)},
+		// and back to winter time on sunday 2026-11-01
+		{"America/New_York", time.Date(2026, 10, 30, 20, 0, 0, 0, time.UTC), time.Date(2026, 11, 2, 14, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		calendar := testCalendar(t, test.timezone, WorkingHours{}, nil)
+		got := calendar.NextWorkdayAt(test.now, 9, 0)
+		if !got.Equal(test.want) {
+			t.Errorf("%s %v: wanted %v, got %v", test.timezone, test.now, test.want, got.UTC())
+		}
+	}
+
+	// A week working on sundays hits the transition day itself
+	calendar := testCalendar(t, "Europe/Helsinki", WorkingHours{}, []string{"sun", "mon", "tue", "wed", "thu"})
+	got := calendar.NextWorkdayAt(time.Date(2026, 3, 28, 12, 0, 0, 0, time.UTC), 9, 0)
+	if want := time.Date(2026, 3, 29, 6, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Sunday of DST start: wanted %v, got %v", want, got.UTC())
+	}
+	got = calendar.NextWorkdayAt(time.Date(2026, 10, 24, 12, 0, 0, 0, time.UTC), 9, 0)
+	if want := time.Date(2026, 10, 25, 7, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Sunday of DST end: wanted %v, got %v", want, got.UTC())
+	}
+}
+
+func TestCalendarDefer(t *testing.T) {
+	calendar := testCalendar(t, "Europe/Helsinki", WorkingHours{Start: "08:30", End: "17:00"}, nil)
+	location := calendar.Location
+	calendar.Holidays = NewHolidays()
+	calendar.Holidays.Add(time.Date(2026, 10, 19, 0, 0, 0, 0, location))
+
+	tests := []struct {
+		t        time.Time
+		want     time.Time
+		deferred bool
+	}{
+		// Within working hours
+		{time.Date(2026, 10, 14, 10, 0, 0, 0, location), time.Date(2026, 10, 14, 10, 0, 0, 0, location), false},
+		// Late at night rolls to the next morning
+		{time.Date(2026, 10, 14, 23, 30, 0, 0, location), time.Date(2026, 10, 15, 8, 30, 0, 0, location), true},
+		// Early morning rolls to the same morning
+		{time.Date(2026, 10, 15, 0, 30, 0, 0, location), time.Date(2026, 10, 15, 8, 30, 0, 0, location), true},
+		// End of the window is outside it
+		{time.Date(2026, 10, 15, 17, 0, 0, 0, location), time.Date(2026, 10, 16, 8, 30, 0, 0, location), true},
+		// Friday evening rolls over the weekend and the holiday on monday
+		{time.Date(2026, 10, 16, 18, 0, 0, 0, location), time.Date(2026, 10, 20, 8, 30, 0, 0, location), true},
+		// Saturday noon
+		{time.Date(2026, 10, 17, 12, 0, 0, 0, location), time.Date(2026, 10, 20, 8, 30, 0, 0, location), true},
+	}
+	for _, test := range tests {
+		got, deferred := calendar.Defer(test.t)
+		if !got.Equal(test.want) || deferred != test.deferred {
+			t.Errorf("Defer(%v): wanted %v (deferred %v), got %v (deferred %v)", test.t, test.want, test.deferred, got, deferred)
+		}
+	}
+}
+
+func TestCalendarDeferDST(t *testing.T) {
+	calendar := testCalendar(t, "Europe/Helsinki", WorkingHours{Start: "08:00", End: "16:00"}, []string{"sun", "mon", "tue", "wed", "thu"})
+
+	// 02:30 on the sunday summer time starts, working hours begin at 08:00 EEST
+	got, deferred := calendar.Defer(time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC))
+	if want := time.Date(2026, 3, 29, 5, 0, 0, 0, time.UTC); !got.Equal(want) || !deferred {
+		t.Errorf("DST start: wanted %v, got %v", want, got.UTC())
+	}
+	// 15:30 EET on the sunday winter time starts is within working hours
+	got, deferred = calendar.Defer(time.Date(2026, 10, 25, 13, 30, 0, 0, time.UTC))
+	if deferred {
+		t.Errorf("DST end: %v should not be deferred", got.UTC())
+	}
+}
+
+func TestCalendarDeferUnrestricted(t *testing.T) {
+	calendar := testCalendar(t, "Europe/Helsinki", WorkingHours{}, nil)
+	saturdayNight := time.Date(2026, 10, 17, 23, 0, 0, 0, time.UTC)
+	if got, deferred := calendar.Defer(saturdayNight); deferred || !got.Equal(saturdayNight) {
+		t.Errorf("Expected no deferral without working hours, got %v", got)
+	}
+}
+
+func TestNewCalendar(t *testing.T) {
+	calendar, err := NewCalendar(WorkingHours{}, []string{"sun", "Monday", "tue", "wed", "thu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calendar.Start != 0 || calendar.End != 24*time.Hour || calendar.Restricted {
+		t.Errorf("Expected the whole day without restrictions, got %+v", calendar)
+	}
+	friday := time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC)
+	if got, deferred := calendar.Defer(friday); deferred || !got.Equal(friday) {
+		t.Errorf("Expected no deferral with working days alone, got %v", got)
+	}
+	if !calendar.Workdays[time.Sunday] || calendar.Workdays[time.Friday] || calendar.Workdays[time.Saturday] {
+		t.Errorf("Unexpected working days %v", calendar.Workdays)
+	}
+
+	for _, invalid := range []struct {
+		hours WorkingHours
+		days  []string
+	}{
+		{WorkingHours{Start: "17:00", End: "09:00"}, nil},
+		{WorkingHours{Start: "9am"}, nil},
+		{WorkingHours{}, []string{"someday"}},
+	} {
+		if _, err := NewCalendar(invalid.hours, invalid.days); err == nil {
+			t.Errorf("Expected %v %v to be invalid", invalid.hours, invalid.days)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// cancelCommand handles the cancel command sent by the user with nick in the
+// thread stored under key and returns the reply to send back. "<nick>"
+// cancels the pings of the user to nick in the thread, "all" all of their
+// pending pings. The pinging messages are tagged cancelled-<target>.
+func cancelCommand(nick, key, args string) string {
+	target := strings.ToLower(strings.TrimSpace(args))
+	if target == "" || strings.ContainsAny(target, " \t") {
+		return fmt.Sprintf("Usage: %[1]scancel <nick> to cancel your pings to nick in this thread, %[1]scancel all to cancel all your pings.", prefix)
+	}
+	if target == "me" {
+		target = strings.ToLower(nick)
+	}
+	if target != "all" && !users.Exists(target) {
+		return fmt.Sprintf("Unknown user '%s'.", target)
+	}
+
+	cancelled := 0
+	for to, threads := range notifications.ByUser {
+		if target != "all" && to != users[target] {
+			continue
+		}
+		for threadID, notifs := range threads {
+			if target != "all" && threadID != key {
+				continue
+			}
+			for _, notif := range notifs {
+				if !strings.EqualFold(notif.Pinger, nick) {
+					continue
+				}
+				notifications.Remove(notif, to, threadID)
+				cancelled++
+				log.Printf("%s cancelled the notification for %s in %s", nick, to, threadID)
+				if org, flow, ok := flowNames(notif.Flow); ok {
+					api.EditMessage(org, flow, strconv.FormatInt(notif.MessageID, 10), "", []string{fmt.Sprintf("cancelled-%s", users.Nick(to))})
+				}
+			}
+		}
+	}
+	if cancelled == 0 {
+		if target == "all" {
+			return "You have no pending pings."
+		}
+		return fmt.Sprintf("You have no pending pings to %s in this thread.", target)
+	}
+	err := notifications.Save(notificationStorage)
+	if err != nil {
+		log.Println(err)
+	}
+	return fmt.Sprintf("Cancelled %d ping(s).", cancelled)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// chatMessage is a message or comment that may contain commands and pings
+type chatMessage struct {
+	UserID  string
+	Nick    string
+	Content string
+	// Conversation the bot replies and delivers notifications to
+	Conversation Conversation
+	ID           int64
+	Sent         time.Time
+	Reply        func(message string)
+	Tag          func(tags []string)
+}
+
+// messageLink returns a link to the message with messageID in the flow
+func messageLink(flowID string, messageID int64) string {
+	flow := flows[flowID]
+	return fmt.Sprintf("https://www.flowdock.com/app/%s/%s/messages/%d", flow.Organization.APIName, flow.APIName, messageID)
+}
+
+// handleCommands replies to the commands in msg
+func handleCommands(msg chatMessage) {
+	command := func(name string) (string, bool) {
+		return commandArgs(msg.Content, name)
+	}
+
+	if _, ok := command("help"); ok {
+		msg.Reply(helpMessage)
+	}
+	if args, ok := command("snooze"); ok {
+		msg.Reply(snoozeCommand(msg.Nick, msg.UserID, msg.Conversation.Key(), args))
+	}
+	if args, ok := command("timezone"); ok {
+		msg.Reply(timezoneCommand(msg.Nick, args))
+	}
+	if args, ok := command("away"); ok {
+		msg.Reply(awayCommand(msg.Nick, args, msg.Sent))
+	}
+	if args, ok := command("cancel"); ok {
+		msg.Reply(cancelCommand(msg.Nick, msg.Conversation.Key(), args))
+	}
+	if args, ok := command("list"); ok {
+		msg.Reply(listCommand(msg.Nick, msg.UserID, msg.Conversation.Key(), args))
+	}
+	if args, ok := command("recurring"); ok {
+		msg.Reply(recurringCommand(msg.Nick, msg.UserID, args))
+	}
+}
+
+// commands are the names of the commands the bot answers to
+var commands = []string{"help", "snooze", "timezone", "away", "cancel", "list", "recurring"}
+
+// commandArgs returns the arguments of the command name if content is that
+// command, i.e. the prefixed name followed by whitespace or nothing
+func commandArgs(content, name string) (string, bool) {
+	if !strings.HasPrefix(content, prefix+name) {
+		return "", false
+	}
+	args := strings.TrimPrefix(content, prefix+name)
+	if r, _ := utf8.DecodeRuneInString(args); args != "" && !unicode.IsSpace(r) {
+		return "", false
+	}
+	return args, true
+}
+
+// isCommand returns true if content is one of the commands of the bot
+func isCommand(content string) bool {
+	for _, name := range commands {
+		if _, ok := commandArgs(content, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// schedulePings creates notifications for the pings in msg
+func schedulePings(msg chatMessage) {
+	for _, p := range findPings(msg.Content, msg.Nick) {
+		notification, tags, ok := pingNotification(msg, p)
+		if !ok {
+			continue
+		}
+		notifications.Add(notification, users[p.nick], msg.Conversation.Key())
+		msg.Tag(tags)
+		notifications.Save(notificationStorage)
+	}
+}
+
+// pingNotification returns the notification for the ping p in msg and the
+// tags to add to the message, replying to msg if the ping is invalid or
+// deferred. It returns false if no notification is created.
+func pingNotification(msg chatMessage, p ping) (Notification, []string, bool) {
+	possibleUsername := p.nick
+	pinger := msg.Nick
+
+	var recurrence Recurrence
+	var notifyTime time.Time
+	var notifyTag string
+	var err error
+	if r, _, ok := ParseRecurrence(p.rest, calendarFor(p.nick).Workdays); ok && p.kind == "" {
+		recurrence = r
+		notifyTime = r.Next(msg.Sent, locationFor(p.nick))
+		notifyTag = fmt.Sprintf("notify-every-%v", p.nick)
+	} else {
+		notifyTime, notifyTag, err = notifyTimeAndTag(p, msg.Sent)
+	}
+	if err != nil {
+		log.Printf("%s requested notification for %s with invalid time: %v", pinger, possibleUsername, err)
+		msg.Reply(fmt.Sprintf("%v. %s", err, deliveryTimeHelp()))
+		return Notification{}, nil, false
+	}
+	if notifyTime.IsZero() {
+		log.Println("No time was set for notification")
+		return Notification{}, nil, false
+	}
+	tags := []string{notifyTag}
+	if recurrence.IsZero() {
+		var deferred bool
+		notifyTime, deferred = calendarFor(possibleUsername).Defer(notifyTime)
+		if deferred {
+			tags = append(tags, fmt.Sprintf("deferred-%v", possibleUsername))
+			localTime := notifyTime.In(locationFor(possibleUsername)).Format("Mon 15:04 MST")
+			msg.Reply(fmt.Sprintf("That is outside the working hours of %s, the ping was deferred to %s.", possibleUsername, localTime))
+		}
+	}
+	if !p.self {
+		var until time.Time
+		notifyTime, until = deferForAway(possibleUsername, notifyTime)
+		if !until.IsZero() {
+			tags = append(tags, fmt.Sprintf("away-%v", possibleUsername))
+			location := locationFor(possibleUsername)
+			msg.Reply(fmt.Sprintf("%s is away until %s, the ping will land %s.", possibleUsername, until.In(location).Format("Mon 2 Jan"), notifyTime.In(location).Format("Mon 2 Jan 15:04 MST")))
+		}
+	}
+	if status, away := awayByStatus(users[possibleUsername]); away && !p.self {
+		msg.Reply(fmt.Sprintf("The status of %s is '%s', the ping will be held until they change it.", possibleUsername, status))
+	}
+	log.Printf("%s requested notification for %s at %v", pinger, possibleUsername, notifyTime)
+	notification := NewNotification(notifyTime, pinger, msg.Conversation.Thread, msg.Conversation.Flow, msg.ID)
+	notification.Parent = msg.Conversation.Parent
+	notification.Recurrence = recurrence
+	notification.Self = p.self
+	notification.Tier = utf8.RuneCountInString(p.prefix)
+	notification.Reason = pingReason(p, msg.Content, msg.Sent)
+	return notification, tags, true
+}
+
+// userNotification is a notification and the user it is for
+type userNotification struct {
+	Notification
+	To string // user ID
+}
+
+type byTimestamp []userNotification
+
+func (n byTimestamp) Len() int           { return len(n) }
+func (n byTimestamp) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+func (n byTimestamp) Less(i, j int) bool { return n[i].Timestamp.Before(n[j].Timestamp) }
+
+// recurringCommand handles the recurring command sent by the user with nick
+// and ID. Without arguments it lists the recurring notifications the user has
+// created or receives, "cancel <id>" cancels those created from the message
+// with the given ID.
+func recurringCommand(nick, userID, args string) string {
+	var recurring byTimestamp
+	for to, threads := range notifications.ByUser {
+		for _, notifs := range threads {
+			for _, notif := range notifs {
+				if !notif.Recurrence.IsZero() && (to == userID || strings.EqualFold(notif.Pinger, nick)) {
+					recurring = append(recurring, userNotification{notif, to})
+				}
+			}
+		}
+	}
+	sort.Sort(recurring)
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		if len(recurring) == 0 {
+			return "You have no recurring pings."
+		}
+		location := locationFor(nick)
+		message := "Recurring pings:\n"
+		for _, notif := range recurring {
+			message += fmt.Sprintf("- %s for %s from %s, next %s, created [here](%s) (id %d)\n",
+				notif.Recurrence, users.Nick(notif.To), notif.Pinger, notif.Timestamp.In(location).Format("Mon 15:04 MST"), messageLink(notif.Flow, notif.MessageID), notif.MessageID)
+		}
+		return message + fmt.Sprintf("Cancel one with %srecurring cancel <id>.", prefix)
+	}
+
+	if fields[0] != "cancel" || len(fields) != 2 {
+		return fmt.Sprintf("Usage: %[1]srecurring to list your recurring pings, %[1]srecurring cancel <id> to cancel one.", prefix)
+	}
+	messageID, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return fmt.Sprintf("Invalid id '%s'.", fields[1])
+	}
+	cancelled := 0
+	for to, threads := range notifications.ByUser {
+		for threadID, notifs := range threads {
+			for _, notif := range notifs {
+				if notif.MessageID == messageID && !notif.Recurrence.IsZero() && (to == userID || strings.EqualFold(notif.Pinger, nick)) {
+					log.Printf("%s cancelled the recurring notification %s for %s", nick, notif.Recurrence, to)
+					notifications.Remove(notif, to, threadID)
+					cancelled++
+				}
+			}
+		}
+	}
+	if cancelled == 0 {
+		return fmt.Sprintf("You have no recurring ping with id %d.", messageID)
+	}
+	notifications.Save(notificationStorage)
+	return fmt.Sprintf("Cancelled %d recurring ping(s).", cancelled)
+}
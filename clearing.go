@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Clearing policies, deciding which activity of the target clears a ping
+const (
+	clearThread  = "thread"  // a message or comment in the same conversation
+	clearFlow    = "flow"    // a message or comment anywhere in the same flow
+	clearComment = "comment" // a comment on the pinging message
+	clearTag     = "tag"     // any tag added to the pinging message
+	clearNever   = "never"   // no activity, only acknowledging or cancelling
+)
+
+// Clearing configures the clearing policy, by default and per flow. Tiers
+// may override both.
+type Clearing struct {
+	Default string            `yaml:"default"`
+	Flows   map[string]string `yaml:"flows"` // by flow ID or org/flow
+}
+
+// clearing is the configured clearing policy
+var clearing = Clearing{Default: clearThread}
+
+// validateClearing returns an error if policy is not a known clearing policy
+func validateClearing(policy string) error {
+	switch policy {
+	case clearThread, clearFlow, clearComment, clearTag, clearNever:
+		return nil
+	}
+	return fmt.Errorf("unknown clearing policy '%s'", policy)
+}
+
+// Validate returns an error if any of the policies is unknown
+func (c Clearing) Validate() error {
+	if err := validateClearing(c.Default); err != nil {
+		return err
+	}
+	for flow, policy := range c.Flows {
+		if err := validateClearing(policy); err != nil {
+			return fmt.Errorf("flow %s: %v", flow, err)
+		}
+	}
+	return nil
+}
+
+// clearingPolicy returns the clearing policy of notif: that of its tier,
+// else that of its flow, else the default
+func clearingPolicy(notif Notification) string {
+	if tier, found := tiers.Find(notif.Tier); found && tier.Clearing != "" {
+		return tier.Clearing
+	}
+	if policy, ok := clearing.Flows[notif.Flow]; ok {
+		return policy
+	}
+	if flow, ok := flows[notif.Flow]; ok {
+		if policy, ok := clearing.Flows[flow.Organization.APIName+"/"+flow.APIName]; ok {
+			return policy
+		}
+	}
+	if clearing.Default == "" {
+		return clearThread
+	}
+	return clearing.Default
+}
+
+// clearedBy returns a matcher for the notifications cleared by activity of
+// their target in the conversation, which is a comment if comment is true
+func clearedBy(conversation Conversation, comment bool) func(key string, notif Notification) bool {
+	return func(key string, notif Notification) bool {
+		switch clearingPolicy(notif) {
+		case clearThread:
+			return key == conversation.Key()
+		case clearFlow:
+			return notif.Flow == conversation.Flow
+		case clearComment:
+			return comment && notif.Flow == conversation.Flow &&
+				(conversation.Parent == strconv.FormatInt(notif.MessageID, 10) || notif.Parent != "" && conversation.Parent == notif.Parent)
+		}
+		return false
+	}
+}
+
+// clearingHelp returns the help text describing the configured clearing
+// policy
+func clearingHelp() string {
+	var help string
+	switch clearing.Default {
+	case clearFlow:
+		help = "Pings to a target are cleared when they write anywhere in the same flow."
+	case clearComment:
+		help = "Pings to a target are cleared when they comment on the pinging message."
+	case clearTag:
+		help = "Pings to a target are cleared when they tag the pinging message."
+	case clearNever:
+		help = "Pings are not cleared by the target's activity."
+	default:
+		help = "Pings to a target are cleared when they write in the same thread."
+	}
+	differs := len(clearing.Flows) > 0
+	for _, tier := range tiers {
+		differs = differs || tier.Clearing != ""
+	}
+	if differs {
+		help += " Some flows or tiers clear differently."
+	}
+	return help
+}
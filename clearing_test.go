@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestClearingHelp(t *testing.T) {
+	defer func() { clearing, tiers = Clearing{Default: clearThread}, defaultTiers }()
+	tiers = defaultTiers
+
+	clearing = Clearing{Default: clearThread}
+	if got, want := clearingHelp(), "Pings to a target are cleared when they write in the same thread."; got != want {
+		t.Errorf("Wanted %q, got %q", want, got)
+	}
+	clearing = Clearing{Default: clearNever, Flows: map[string]string{"org/flow": clearFlow}}
+	if got, want := clearingHelp(), "Pings are not cleared by the target's activity. Some flows or tiers clear differently."; got != want {
+		t.Errorf("Wanted %q, got %q", want, got)
+	}
+}
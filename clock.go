@@ -0,0 +1,31 @@
+package main
+
+import "time"
+
+// Clock tells the time and runs functions after a delay, so that scheduling
+// can be driven by a fake clock in tests
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a function scheduled with a Clock
+type Timer interface {
+	Stop() bool
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+// Now returns the current time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc calls f in its own goroutine after d
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// clock is the clock used for everything time related
+var clock Clock = realClock{}
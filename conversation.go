@@ -0,0 +1,30 @@
+package main
+
+// Conversation identifies where a ping was made and is delivered: a thread of
+// a flow, or the comments on a message of a flow
+type Conversation struct {
+	Flow   string // flow ID
+	Thread string // thread ID of a message thread
+	Parent string // ID of the message commented on, for comments
+}
+
+// Key returns the key notifications in the conversation are stored under
+func (c Conversation) Key() string {
+	if c.Parent != "" {
+		return c.Flow + "/comments/" + c.Parent
+	}
+	return c.Flow + "/" + c.Thread
+}
+
+// Valid returns true if messages can be sent to the conversation
+func (c Conversation) Valid() bool {
+	return c.Flow != "" && (c.Thread != "" || c.Parent != "")
+}
+
+// Send sends a message to the conversation, as a comment if it is one
+func (c Conversation) Send(message string) ([]byte, error) {
+	if c.Parent != "" {
+		return api.SendComment(c.Flow, c.Parent, message)
+	}
+	return api.SendMessage(c.Flow, c.Thread, message)
+}
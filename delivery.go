@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// bundleWindow is how soon a ping has to be due to be delivered together with
+// the pings in the same thread that are due now
+const bundleWindow = 5 * time.Minute
+
+// Delivery is a message the bot has sent to deliver notifications
+type Delivery struct {
+	Notifications []Notification
+	Key           string // thread key the notifications were stored under
+	MessageID     int64  // ID of the message the bot sent
+}
+
+// Deliveries is a map of the latest delivery by user and conversation key
+type Deliveries map[string]map[string]Delivery
+
+// NewDeliveries returns an empty deliveries map
+func NewDeliveries() Deliveries {
+	return make(map[string]map[string]Delivery)
+}
+
+// Add adds a delivery to the map, replacing an earlier one in the thread
+func (d Deliveries) Add(dd Delivery, to, threadID string) {
+	if _, exists := d[to]; !exists {
+		d[to] = make(map[string]Delivery)
+	}
+	d[to][threadID] = dd
+}
+
+// Delete deletes a delivery from the map
+func (d Deliveries) Delete(to, threadID string) {
+	delete(d[to], threadID)
+}
+
+// sentMessageID returns the ID of the message in the response body of the
+// Flowdock API, or 0 if there is none
+func sentMessageID(body []byte) int64 {
+	var message struct {
+		ID int64 `json:"id"`
+	}
+	json.Unmarshal(body, &message)
+	return message.ID
+}
+
+// deliverDueNotifications delivers or escalates the notifications that are
+// due at now
+func deliverDueNotifications(now time.Time) {
+	due := scheduler.Due(now)
+	for _, s := range due {
+		userID, threadID := s.UserID, s.Key
+		// Plain pings due within the bundle window are delivered together
+		var pings []Notification
+		for _, notif := range notifications.Due(userID, threadID, now) {
+			if until, away := awayUntil(users.Nick(userID), now); away && notif.DeliveredAt.IsZero() && !notif.Self {
+				notif.Timestamp = returnTime(users.Nick(userID), until)
+				log.Printf("User %v is away, deferring notification to %v", userID, notif.Timestamp)
+				notifications.Add(notif, userID, threadID)
+			} else if _, away := awayByStatus(userID); away && notif.DeliveredAt.IsZero() && !notif.Self {
+				holdForStatus(userID, threadID, notif, now)
+			} else if notif.DeliveredAt.IsZero() && waitForPresence(userID, threadID, notif, now) {
+				// delivered once the target shows activity
+			} else if notif.DeliveredAt.IsZero() && notif.ClearedByActivity() {
+				pings = append(pings, notif)
+			} else if notif.DeliveredAt.IsZero() {
+				deliver(userID, threadID, []Notification{notif}, now)
+			} else {
+				escalate(userID, threadID, notif)
+			}
+		}
+		if len(pings) > 0 {
+			deliver(userID, threadID, append(pings, upcomingPings(userID, threadID, now)...), now)
+		}
+	}
+	if len(due) > 0 {
+		notifications.Save(notificationStorage)
+	}
+}
+
+// upcomingPings returns the undelivered pings to the user with userID in the
+// thread that are due after now but within bundleWindow
+func upcomingPings(userID, threadID string, now time.Time) []Notification {
+	var pings []Notification
+	for _, notif := range notifications.ByUser[userID][threadID] {
+		if notif.DeliveredAt.IsZero() && notif.ClearedByActivity() && !notif.Held &&
+			notif.Timestamp.After(now) && !notif.Timestamp.After(now.Add(bundleWindow)) {
+			pings = append(pings, notif)
+		}
+	}
+	return pings
+}
+
+// joinNames returns the given names as "a, b and c"
+func joinNames(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// quoteReason returns the reason of notif quoted for a delivery message, with
+// the given separator in front, or nothing if it has none
+func quoteReason(separator string, notif Notification) string {
+	if notif.Reason == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s\"%s\"", separator, notif.Reason)
+}
+
+// deliveryMessage returns the message that delivers notifs to pingUser, who
+// is mentioned once for all of them
+func deliveryMessage(pingUser string, notifs []Notification) string {
+	notif := notifs[0]
+	localTime := notif.Timestamp.In(locationFor(pingUser)).Format("Mon 15:04 MST")
+	link := messageLink(notif.Flow, notif.MessageID)
+	if len(notifs) > 1 {
+		var pingers []string
+		for _, notif := range notifs {
+			pingers = append(pingers, fmt.Sprintf("%v from [here](%s)%s", strings.Title(notif.Pinger), messageLink(notif.Flow, notif.MessageID), quoteReason(" ", notif)))
+		}
+		return fmt.Sprintf("@%v, slow pings from %s", pingUser, joinNames(pingers))
+	}
+	message := fmt.Sprintf("@%v, slow ping from %v from [here](%s) for %s", pingUser, strings.Title(notif.Pinger), link, localTime)
+	if !notif.Recurrence.IsZero() {
+		message = fmt.Sprintf("@%v, recurring ping (%s) from %v from [here](%s)", pingUser, notif.Recurrence, strings.Title(notif.Pinger), link)
+	}
+	if notif.Self {
+		message = fmt.Sprintf("@%v, reminder you set [here](%s) for %s", pingUser, link, localTime)
+		if !notif.Recurrence.IsZero() {
+			message = fmt.Sprintf("@%v, recurring reminder you set (%s) [here](%s)", pingUser, notif.Recurrence, link)
+		}
+	}
+	return message + quoteReason(": ", notif)
+}
+
+// deliver sends notifs, stored for the user with userID under threadID, in
+// one message. Afterwards each notification is rescheduled if it
+// recurs, kept for escalation or removed.
+func deliver(userID, threadID string, notifs []Notification, now time.Time) {
+	log.Printf("Sending %d notification(s) due to no activity at %s", len(notifs), now)
+	pingUser := users.Nick(userID)
+	message := deliveryMessage(pingUser, notifs)
+	var body []byte
+	var err error
+	if conversation := notifs[0].Conversation(); conversation.Valid() {
+		body, err = conversation.Send(message)
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+	log.Printf("%v\n", string(body))
+	deliveries.Add(Delivery{notifs, threadID, sentMessageID(body)}, userID, threadID)
+
+	for _, notif := range notifs {
+		steps := escalationFor(notif)
+		switch {
+		case !notif.Recurrence.IsZero():
+			notif.Timestamp = notif.Recurrence.Next(now, locationFor(pingUser))
+			notif.WaitingSince = time.Time{}
+			log.Printf("Rescheduled recurring notification %s to %v", notif.Recurrence, notif.Timestamp)
+			notifications.Add(notif, userID, threadID)
+		case len(steps) > 0:
+			notif.DeliveredAt = now
+			notif.Timestamp = now.Add(steps[0].After)
+			log.Printf("Escalating notification for %s at %v unless they are active", pingUser, notif.Timestamp)
+			notifications.Add(notif, userID, threadID)
+		default:
+			notifications.Remove(notif, userID, threadID)
+		}
+	}
+}
+
+// escalate takes the next escalation step of a delivered notification the
+// target has not reacted to
+func escalate(userID, threadID string, notif Notification) {
+	steps := escalationFor(notif)
+	if notif.Escalated >= len(steps) {
+		notifications.Remove(notif, userID, threadID)
+		return
+	}
+	pingUser := users.Nick(userID)
+	link := messageLink(notif.Flow, notif.MessageID)
+	step := steps[notif.Escalated]
+	log.Printf("Escalating notification for %s from %s: %s", pingUser, notif.Pinger, step.Action)
+
+	var err error
+	switch step.Action {
+	case escalateRepeat:
+		message := fmt.Sprintf("@%v, the slow ping from %v from [here](%s) is still waiting for you", pingUser, strings.Title(notif.Pinger), link)
+		_, err = notif.Conversation().Send(message)
+	case escalatePrivate:
+		message := fmt.Sprintf("%v is waiting for your answer [here](%s)", strings.Title(notif.Pinger), link)
+		_, err = api.SendPrivateMessage(userID, message)
+	case escalateNotifyPinger:
+		message := fmt.Sprintf("%v has not answered your ping [here](%s)", pingUser, link)
+		_, err = api.SendPrivateMessage(users[strings.ToLower(notif.Pinger)], message)
+	}
+	if err != nil {
+		log.Printf("Error could not escalate notification: %v", err)
+	}
+
+	notif.Escalated++
+	if notif.Escalated < len(steps) {
+		notif.Timestamp = notif.DeliveredAt.Add(steps[notif.Escalated].After)
+		notifications.Add(notif, userID, threadID)
+	} else {
+		notifications.Remove(notif, userID, threadID)
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSentMessageID(t *testing.T) {
+	if id := sentMessageID([]byte(`{"event":"message","id":12345,"content":"@bob, slow ping"}`)); id != 12345 {
+		t.Errorf("Wanted 12345, got %d", id)
+	}
+	if id := sentMessageID([]byte(`not json`)); id != 0 {
+		t.Errorf("Wanted 0, got %d", id)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gnyman/flowdock"
+)
+
+// maxRecentMessages is the number of messages remembered for reconciling edits
+const maxRecentMessages = 1000
+
+// recentMessage is what is remembered of a message for reconciling its edits
+type recentMessage struct {
+	Conversation Conversation
+	Sent         time.Time
+	Content      string
+}
+
+// RecentMessages remembers the latest messages by flow and message ID
+type RecentMessages struct {
+	messages map[string]recentMessage
+	order    []string
+}
+
+// NewRecentMessages returns an empty message memory
+func NewRecentMessages() *RecentMessages {
+	return &RecentMessages{messages: make(map[string]recentMessage)}
+}
+
+// recentMessages are the messages seen since the bot started
+var recentMessages = NewRecentMessages()
+
+func recentKey(flowID string, messageID int64) string {
+	return fmt.Sprintf("%s/%d", flowID, messageID)
+}
+
+// Add remembers a message, forgetting the oldest one if there are too many
+func (r *RecentMessages) Add(flowID string, messageID int64, message recentMessage) {
+	key := recentKey(flowID, messageID)
+	if _, exists := r.messages[key]; !exists {
+		r.order = append(r.order, key)
+	}
+	r.messages[key] = message
+	if len(r.order) > maxRecentMessages {
+		delete(r.messages, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+// Get returns the remembered message with messageID in the flow
+func (r *RecentMessages) Get(flowID string, messageID int64) (recentMessage, bool) {
+	message, found := r.messages[recentKey(flowID, messageID)]
+	return message, found
+}
+
+// messagePing is a notification a message asks for, with the tags and
+// replies that go with it
+type messagePing struct {
+	Notification Notification
+	Tags         []string
+	Replies      []string
+}
+
+// messagePings returns the notifications msg asks for by target user ID,
+// without replying to it
+func messagePings(msg chatMessage) map[string]messagePing {
+	pings := make(map[string]messagePing)
+	for _, p := range findPings(msg.Content, msg.Nick) {
+		var replies []string
+		msg.Reply = func(message string) {
+			replies = append(replies, message)
+		}
+		notification, tags, ok := pingNotification(msg, p)
+		if ok {
+			pings[users[p.nick]] = messagePing{notification, tags, replies}
+		}
+	}
+	return pings
+}
+
+// sameSchedule returns true if a and b ask for a notification at the same time
+func sameSchedule(a, b Notification) bool {
+	return a.Timestamp.Equal(b.Timestamp) && a.Tier == b.Tier && a.Recurrence == b.Recurrence && a.Self == b.Self
+}
+
+// cancelDeleted cancels every notification created from the message with
+// messageID in the flow, which nick deleted
+func cancelDeleted(flowID string, messageID int64, nick string) {
+	cancelled := 0
+	for to, threads := range notifications.ByUser {
+		for key, notifs := range threads {
+			for _, notif := range notifs {
+				if notif.Flow == flowID && notif.MessageID == messageID {
+					log.Printf("%s deleted message %d, cancelling the notification from %s for %s", nick, messageID, notif.Pinger, users.Nick(to))
+					notifications.Remove(notif, to, key)
+					cancelled++
+				}
+			}
+		}
+	}
+	if cancelled > 0 {
+		notifications.Save(notificationStorage)
+	}
+}
+
+// handleMessageEdit reconciles the notifications of an edited message with its
+// new content: new pings are scheduled, removed ones cancelled and pending
+// ones rescheduled if their time changed. Pings that were already delivered or
+// cleared are not brought back.
+func handleMessageEdit(event flowdock.MessageEditEvent) {
+	messageID := event.Content.MessageID
+	nick := users.Nick(event.UserID)
+	if event.UserID == botUserID {
+		return
+	}
+	if strings.TrimSpace(event.Content.UpdatedMessage) == "" {
+		cancelDeleted(event.Flow, messageID, nick)
+		return
+	}
+	log.Printf("%s edited message %d: '%s'", nick, messageID, event.Content.UpdatedMessage)
+
+	existing := make(map[string]Notification)
+	for to, threads := range notifications.ByUser {
+		for _, notifs := range threads {
+			for _, notif := range notifs {
+				if notif.Flow == event.Flow && notif.MessageID == messageID {
+					existing[to] = notif
+				}
+			}
+		}
+	}
+	recent, remembered := recentMessages.Get(event.Flow, messageID)
+	if !remembered {
+		// Without the original content the pending notifications tell
+		// where the message was sent
+		if len(existing) == 0 {
+			log.Printf("Ignoring the edit of message %d that is not known", messageID)
+			return
+		}
+		for _, notif := range existing {
+			recent = recentMessage{Conversation: notif.Conversation(), Sent: sentTime(event.Timestamp)}
+		}
+	}
+	org, flow, ok := flowNames(event.Flow)
+	if !ok {
+		log.Printf("Odd, we got an edit from a flow we do not know")
+		return
+	}
+	tag := func(tags []string) {
+		api.EditMessage(org, flow, strconv.FormatInt(messageID, 10), "", tags)
+	}
+
+	msg := chatMessage{
+		UserID:       event.UserID,
+		Nick:         nick,
+		Content:      recent.Content,
+		Conversation: recent.Conversation,
+		ID:           messageID,
+		Sent:         recent.Sent,
+	}
+	before := messagePings(msg)
+	if !remembered {
+		for to, notif := range existing {
+			before[to] = messagePing{Notification: notif}
+		}
+	}
+	msg.Content = event.Content.UpdatedMessage
+	after := messagePings(msg)
+	recent.Content = event.Content.UpdatedMessage
+	recentMessages.Add(event.Flow, messageID, recent)
+
+	changed := 0
+	for to, notif := range existing {
+		if _, wanted := after[to]; wanted {
+			continue
+		}
+		log.Printf("Edit removed the ping to %s, cancelling notification", to)
+		notifications.Remove(notif, to, notif.Conversation().Key())
+		tag([]string{fmt.Sprintf("cancelled-%s", users.Nick(to))})
+		changed++
+	}
+	for to, ping := range after {
+		old, pinged := before[to]
+		pending, found := existing[to]
+		switch {
+		case found && !sameSchedule(old.Notification, ping.Notification):
+			log.Printf("Edit changed the ping to %s, rescheduling notification to %v", to, ping.Notification.Timestamp)
+			notif := ping.Notification
+			notif.DeliveredAt = pending.DeliveredAt
+			notif.Escalated = pending.Escalated
+			notif.Held = pending.Held
+			notif.WaitingSince = pending.WaitingSince
+			notifications.Add(notif, to, pending.Conversation().Key())
+			tag(ping.Tags)
+		case found:
+			if pending.Reason == ping.Notification.Reason {
+				continue
+			}
+			pending.Reason = ping.Notification.Reason
+			notifications.Add(pending, to, pending.Conversation().Key())
+			changed++
+			continue
+		case pinged:
+			// Delivered or cleared already
+			continue
+		default:
+			notifications.Add(ping.Notification, to, recent.Conversation.Key())
+			tag(ping.Tags)
+		}
+		for _, reply := range ping.Replies {
+			recent.Conversation.Send(reply)
+		}
+		changed++
+	}
+	if changed > 0 {
+		notifications.Save(notificationStorage)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Escalation actions
+const (
+	escalateRepeat       = "repeat"        // ping the target again in the thread
+	escalatePrivate      = "private"       // send the target a private message
+	escalateNotifyPinger = "notify_pinger" // tell the pinger the ping went unanswered
+)
+
+// EscalationStep is taken if the target has not been active in the thread
+// After the delivery of a notification
+type EscalationStep struct {
+	After  time.Duration `yaml:"after"`
+	Action string        `yaml:"action"`
+}
+
+// validateEscalation returns an error if the steps are not valid actions in
+// increasing order of time
+func validateEscalation(steps []EscalationStep) error {
+	var previous time.Duration
+	for _, step := range steps {
+		switch step.Action {
+		case escalateRepeat, escalatePrivate, escalateNotifyPinger:
+		default:
+			return fmt.Errorf("unknown escalation action '%s'", step.Action)
+		}
+		if step.After <= previous {
+			return fmt.Errorf("escalation steps must come after the delivery and each other, got %v", step.After)
+		}
+		previous = step.After
+	}
+	return nil
+}
+
+// escalationFor returns the escalation steps of notif, which are those of its
+// tier. Recurring notifications and reminders to self are not escalated.
+func escalationFor(notif Notification) []EscalationStep {
+	if !notif.Recurrence.IsZero() || notif.Self {
+		return nil
+	}
+	tier, found := tiers.Find(notif.Tier)
+	if !found {
+		return nil
+	}
+	return tier.Escalation
+}
+
+// sendPrivateMessage sends a private 1-to-1 message to the user with userID
+func sendPrivateMessage(apiKey, userID, message string) ([]byte, error) {
+	postURL := fmt.Sprintf("https://api.flowdock.com/private/%s/messages", userID)
+
+	data := url.Values{}
+	data.Set("content", message)
+	data.Set("event", "message")
+
+	req, err := http.NewRequest("POST", postURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(apiKey, "BATMAN")
+
+	client := http.Client{}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
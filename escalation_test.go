@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestEscalationFromConfig(t *testing.T) {
+	content := `
+tiers:
+  - repeat: 1
+    delay: 1h
+    tag: notify
+    escalation:
+      - after: 30m
+        action: repeat
+      - after: 2h
+        action: private
+      - after: 4h
+        action: notify_pinger
+`
+	var conf config
+	if err := yaml.Unmarshal([]byte(content), &conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Tiers.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tiers = conf.Tiers
+	defer func() { tiers = defaultTiers }()
+
+	notification := NewNotification(time.Now(), "pinger", "threadID", "flowID", 0)
+	notification.Tier = 1
+	steps := escalationFor(notification)
+	if len(steps) != 3 || steps[1].After != 2*time.Hour || steps[2].Action != escalateNotifyPinger {
+		t.Errorf("Unexpected escalation steps %+v", steps)
+	}
+
+	notification.Self = true
+	if steps := escalationFor(notification); len(steps) != 0 {
+		t.Errorf("Expected no escalation for a reminder to self, got %+v", steps)
+	}
+	notification.Self = false
+	notification.Tier = 2
+	if steps := escalationFor(notification); len(steps) != 0 {
+		t.Errorf("Expected no escalation for an unknown tier, got %+v", steps)
+	}
+}
+
+func TestValidateEscalation(t *testing.T) {
+	invalid := map[string][]EscalationStep{
+		"unknown action": {{After: time.Hour, Action: "shout"}},
+		"no delay":       {{After: 0, Action: escalateRepeat}},
+		"out of order":   {{After: time.Hour, Action: escalateRepeat}, {After: time.Minute, Action: escalatePrivate}},
+	}
+	for name, steps := range invalid {
+		if err := validateEscalation(steps); err == nil {
+			t.Errorf("Expected %s to be invalid", name)
+		}
+	}
+	if err := validateEscalation(nil); err != nil {
+		t.Errorf("Expected no escalation to be valid, got %v", err)
+	}
+}
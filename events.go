@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gnyman/flowdock"
+)
+
+// handleEvent handles a chat event from the Flowdock stream. Events that need
+// the streaming client, like flow changes, are handled by main.
+func handleEvent(event flowdock.Event) {
+	switch event := event.(type) {
+	case flowdock.MessageEvent:
+		handleMessage(event)
+	case flowdock.CommentEvent:
+		handleComment(event)
+	case flowdock.MessageEditEvent:
+		handleMessageEdit(event)
+	case flowdock.TagChangeEvent:
+		handleTagChange(event)
+	case flowdock.StatusEvent:
+		log.Printf("%s changed their status to '%s'", users.Nick(event.UserID), event.Content)
+		if setStatus(event.UserID, event.Content, clock.Now()) {
+			notifications.Save(notificationStorage)
+			deliverDueNotifications(clock.Now())
+		}
+	case flowdock.UserActivityEvent:
+		// Especially with > 10 people in your org, you will get MANY of these events.
+		if seen(event.UserID, sentTime(event.Content.LastActivityTimestamp)) {
+			deliverDueNotifications(clock.Now())
+		}
+	default:
+		log.Printf("New event of type %T", event)
+	}
+}
+
+// flowNames returns the organization and flow API names of the flow with
+// flowID, which may also be given as "org:flow"
+func flowNames(flowID string) (string, string, bool) {
+	orgNflow := strings.Split(flowID, ":")
+	if len(orgNflow) == 2 {
+		return orgNflow[0], orgNflow[1], true
+	}
+	if _, ok := flows[flowID]; ok {
+		return flows[flowID].Organization.APIName, flows[flowID].APIName, true
+	}
+	return "", "", false
+}
+
+// handleMessage handles a message posted to a flow
+func handleMessage(event flowdock.MessageEvent) {
+	log.Printf("Message event %v", event)
+	if event.UserID == botUserID {
+		// Our own messages, like the help, are not pings
+		return
+	}
+	org, flow, ok := flowNames(event.Flow)
+	if !ok {
+		log.Printf("Odd, we got a message from a flow we do not know, maybe we joined a new channel, reconnecting")
+		return
+	}
+	nick := users.Nick(event.UserID)
+	conversation := Conversation{Flow: event.Flow, Thread: event.ThreadID}
+
+	// Commands, like listing the pings waiting, are not activity that clears them
+	if !isCommand(event.Content) {
+		if cleared := notifications.Clear(event.UserID, clearedBy(conversation, false)); len(cleared) > 0 {
+			log.Printf("User %v was active in thread %v for which he had a notificating pending, clearing notification", event.UserID, event.ThreadID)
+			nickClear := fmt.Sprintf("cleared-%s", nick)
+			api.EditMessage(org, flow, strconv.FormatInt(event.ID, 10), "", []string{nickClear})
+		}
+	}
+
+	msg := chatMessage{
+		UserID:       event.UserID,
+		Nick:         nick,
+		Content:      event.Content,
+		Conversation: conversation,
+		ID:           event.ID,
+		Sent:         sentTime(event.Timestamp),
+		Reply: func(message string) {
+			conversation.Send(message)
+		},
+		Tag: func(tags []string) {
+			api.EditMessage(org, flow, strconv.FormatInt(event.ID, 10), "", tags)
+		},
+	}
+	recentMessages.Add(event.Flow, event.ID, recentMessage{conversation, msg.Sent, msg.Content})
+	handleCommands(msg)
+	schedulePings(msg)
+	if seen(event.UserID, msg.Sent) {
+		deliverDueNotifications(clock.Now())
+	}
+	log.Printf("%s said (%s): '%s'", nick, event.Flow, event.Content)
+}
+
+// handleComment handles a comment on a message or team inbox item
+func handleComment(event flowdock.CommentEvent) {
+	log.Println("Comment event")
+	if event.UserID == botUserID {
+		return
+	}
+	org, flow, ok := flowNames(event.Flow)
+	if !ok {
+		log.Printf("Odd, we got a message from a flow we do not know, maybe we joined a new channel, reconnecting")
+		return
+	}
+	nick := users.Nick(event.UserID)
+
+	log.Printf("%s commented (%s): '%s'", nick, event.Flow, event.Content.Text)
+
+	var messageID string
+
+	for _, tag := range event.Tags {
+		if strings.HasPrefix(tag, "influx:") {
+			messageID = strings.TrimPrefix(tag, "influx:")
+		}
+	}
+
+	conversation := Conversation{Flow: event.Flow, Parent: messageID}
+
+	if !isCommand(event.Content.Text) {
+		if cleared := notifications.Clear(event.UserID, clearedBy(conversation, true)); len(cleared) > 0 {
+			log.Printf("User %v was active in comment thread %v for which he had a notificating pending, clearing notification", event.UserID, messageID)
+			nickClear := fmt.Sprintf("cleared-%s", nick)
+			api.EditMessage(org, flow, strconv.FormatInt(event.ID, 10), "", []string{nickClear})
+		}
+	}
+
+	msg := chatMessage{
+		UserID:       event.UserID,
+		Nick:         nick,
+		Content:      event.Content.Text,
+		Conversation: conversation,
+		ID:           event.ID,
+		Sent:         sentTime(event.Timestamp),
+		Reply: func(message string) {
+			conversation.Send(message)
+		},
+		Tag: func(tags []string) {
+			api.EditMessage(org, flow, strconv.FormatInt(event.ID, 10), "", tags)
+		},
+	}
+	recentMessages.Add(event.Flow, event.ID, recentMessage{conversation, msg.Sent, msg.Content})
+	handleCommands(msg)
+	schedulePings(msg)
+	if seen(event.UserID, msg.Sent) {
+		deliverDueNotifications(clock.Now())
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const dateLayout = "2006-01-02"
+
+// Holidays is a set of dates, as YYYY-MM-DD, on which nobody is expected to be
+// at work
+type Holidays map[string]bool
+
+// NewHolidays returns an empty set of holidays
+func NewHolidays() Holidays {
+	return make(map[string]bool)
+}
+
+// Add adds the date of t to the holidays
+func (h Holidays) Add(t time.Time) {
+	h[t.Format(dateLayout)] = true
+}
+
+// Contains returns true if the date of t, in the location of t, is a holiday
+func (h Holidays) Contains(t time.Time) bool {
+	return h[t.Format(dateLayout)]
+}
+
+// Merge adds all holidays in other to the holidays
+func (h Holidays) Merge(other Holidays) {
+	for date := range other {
+		h[date] = true
+	}
+}
+
+// LoadHolidays reads holidays from the given iCalendar (.ics) or YAML
+// (.yaml, .yml) files
+func LoadHolidays(files ...string) (Holidays, error) {
+	holidays := NewHolidays()
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var parsed Holidays
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".ics":
+			parsed, err = ParseICSHolidays(content)
+		case ".yaml", ".yml":
+			parsed, err = ParseYAMLHolidays(content)
+		default:
+			err = fmt.Errorf("unknown holiday file format")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error could not load holidays from '%s': %v", file, err)
+		}
+		holidays.Merge(parsed)
+	}
+	return holidays, nil
+}
+
+// ParseYAMLHolidays parses a YAML list of dates formatted as YYYY-MM-DD
+func ParseYAMLHolidays(content []byte) (Holidays, error) {
+	var dates []string
+	err := yaml.Unmarshal(content, &dates)
+	if err != nil {
+		return nil, err
+	}
+	holidays := NewHolidays()
+	for _, date := range dates {
+		t, err := time.Parse(dateLayout, date)
+		if err != nil {
+			return nil, err
+		}
+		holidays.Add(t)
+	}
+	return holidays, nil
+}
+
+// ParseICSHolidays parses the all-day events of an iCalendar file. Events
+// spanning several days mark every day up to, but not including, DTEND.
+// Recurrence rules are not expanded.
+func ParseICSHolidays(content []byte) (Holidays, error) {
+	holidays := NewHolidays()
+	var start, end time.Time
+	inEvent := false
+	for _, line := range unfoldICSLines(content) {
+		name, value := splitICSLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent = true
+			start, end = time.Time{}, time.Time{}
+		case name == "END" && value == "VEVENT":
+			if start.IsZero() {
+				return nil, fmt.Errorf("event without DTSTART")
+			}
+			holidays.Add(start)
+			for day := start.AddDate(0, 0, 1); day.Before(end); day = day.AddDate(0, 0, 1) {
+				holidays.Add(day)
+			}
+			inEvent = false
+		case inEvent && (name == "DTSTART" || name == "DTEND"):
+			t, err := parseICSDate(value)
+			if err != nil {
+				return nil, err
+			}
+			if name == "DTSTART" {
+				start = t
+			} else {
+				end = t
+			}
+		}
+	}
+	return holidays, nil
+}
+
+// unfoldICSLines splits content into lines, joining folded continuation lines
+func unfoldICSLines(content []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitICSLine returns the property name, without parameters, and the value
+// of a content line
+func splitICSLine(line string) (string, string) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	name := strings.SplitN(parts[0], ";", 2)[0]
+	return strings.ToUpper(name), strings.TrimSpace(parts[1])
+}
+
+// parseICSDate parses the date part of an iCalendar DATE or DATE-TIME value
+func parseICSDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date '%s'", value)
+	}
+	return time.Parse("20060102", value[:8])
+}
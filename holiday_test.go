@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20260403\r\n" +
+	"SUMMARY:Good Friday\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20260406\r\n" +
+	"SUMMARY:Easter\r\n" +
+	"  Monday\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20261224\r\n" +
+	"DTEND;VALUE=DATE:20261227\r\n" +
+	"SUMMARY:Christmas\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+const testYAML = `
+- 2026-06-19 # Midsummer Eve
+- 2026-06-20
+`
+
+func TestParseICSHolidays(t *testing.T) {
+	holidays, err := ParseICSHolidays([]byte(testICS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, date := range []string{"2026-04-03", "2026-04-06", "2026-12-24", "2026-12-25", "2026-12-26"} {
+		if !holidays[date] {
+			t.Errorf("Expected %s to be a holiday", date)
+		}
+	}
+	if len(holidays) != 5 {
+		t.Errorf("Wanted 5 holidays, got %d: %v", len(holidays), holidays)
+	}
+}
+
+func TestParseYAMLHolidays(t *testing.T) {
+	holidays, err := ParseYAMLHolidays([]byte(testYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holidays) != 2 || !holidays["2026-06-19"] || !holidays["2026-06-20"] {
+		t.Errorf("Unexpected holidays %v", holidays)
+	}
+
+	_, err = ParseYAMLHolidays([]byte("- 19.6.2026"))
+	if err == nil {
+		t.Error("Expected an error for an invalid date")
+	}
+}
+
+func TestLoadHolidays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "holidays")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	icsFile := filepath.Join(dir, "fi.ics")
+	yamlFile := filepath.Join(dir, "fi.yaml")
+	ioutil.WriteFile(icsFile, []byte(testICS), 0600)
+	ioutil.WriteFile(yamlFile, []byte(testYAML), 0600)
+
+	holidays, err := LoadHolidays(icsFile, yamlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holidays) != 7 {
+		t.Errorf("Wanted 7 holidays, got %d: %v", len(holidays), holidays)
+	}
+
+	_, err = LoadHolidays(filepath.Join(dir, "fi.txt"))
+	if err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestNextWorkdayAtSkipsHolidays(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Helsinki")
+	holidays, _ := ParseICSHolidays([]byte(testICS))
+	midsummer, _ := ParseYAMLHolidays([]byte(testYAML))
+	holidays.Merge(midsummer)
+	calendar, _ := NewCalendar(WorkingHours{}, nil)
+	calendar.Location = location
+	calendar.Holidays = holidays
+
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		// Midsummer Eve on Friday, the run continues over the weekend
+		{time.Date(2026, 6, 18, 10, 0, 0, 0, location), time.Date(2026, 6, 22, 9, 0, 0, 0, location)},
+		// Easter, holidays on both sides of the weekend
+		{time.Date(2026, 4, 2, 12, 0, 0, 0, location), time.Date(2026, 4, 7, 9, 0, 0, 0, location)},
+		{time.Date(2026, 4, 4, 8, 0, 0, 0, location), time.Date(2026, 4, 7, 9, 0, 0, 0, location)},
+		// Christmas from Thursday to Saturday
+		{time.Date(2026, 12, 23, 16, 0, 0, 0, location), time.Date(2026, 12, 28, 9, 0, 0, 0, location)},
+		// No holiday in the way
+		{time.Date(2026, 12, 28, 8, 0, 0, 0, location), time.Date(2026, 12, 28, 9, 0, 0, 0, location)},
+	}
+	for _, test := range tests {
+		got := calendar.NextWorkdayAt(test.now, 9, 0)
+		if !got.Equal(test.want) {
+			t.Errorf("NextWorkdayAt(%v): wanted %v, got %v", test.now, test.want, got)
+		}
+	}
+}
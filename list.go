@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// listCommand handles the list command sent by the user with nick and ID in
+// the thread stored under key and returns the reply to send back. Without
+// arguments it lists the pings waiting for the user, "sent" the pings the
+// user created and "thread" the pings pending in the thread.
+func listCommand(nick, userID, key, args string) string {
+	var include func(to, threadID string, notif Notification) bool
+	var title string
+	switch strings.TrimSpace(args) {
+	case "":
+		title = "Pings waiting for you"
+		include = func(to, threadID string, notif Notification) bool {
+			return to == userID
+		}
+	case "sent":
+		title = "Pings you have sent"
+		include = func(to, threadID string, notif Notification) bool {
+			return strings.EqualFold(notif.Pinger, nick) && !notif.Self
+		}
+	case "thread":
+		title = "Pings pending in this thread"
+		include = func(to, threadID string, notif Notification) bool {
+			return threadID == key
+		}
+	default:
+		return fmt.Sprintf("Usage: %[1]slist to list the pings waiting for you, %[1]slist sent for the pings you have sent, %[1]slist thread for the pings in this thread.", prefix)
+	}
+
+	var pending byTimestamp
+	for to, threads := range notifications.ByUser {
+		for threadID, notifs := range threads {
+			for _, notif := range notifs {
+				if include(to, threadID, notif) {
+					pending = append(pending, userNotification{notif, to})
+				}
+			}
+		}
+	}
+	if len(pending) == 0 {
+		return fmt.Sprintf("%s: none.", title)
+	}
+	sort.Sort(pending)
+
+	location := locationFor(nick)
+	table := fmt.Sprintf("%s:\n\n| Target | Pinger | Due | Message |\n| --- | --- | --- | --- |\n", title)
+	for _, notif := range pending {
+		table += fmt.Sprintf("| %s | %s | %s | [here](%s) |\n", users.Nick(notif.To), notif.Pinger, notif.Timestamp.In(location).Format("Mon 2 Jan 15:04 MST"), messageLink(notif.Flow, notif.MessageID))
+	}
+	return table
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gnyman/flowdock"
+)
+
+func TestListCommand(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	defaultLocation = helsinki
+	userConfigs = make(map[string]userConfig)
+	userSettings = NewUserSettings()
+	users = Users{"alice": "1", "bob": "2", "carol": "3"}
+	flows = map[string]flowdock.Flow{"flow": {ID: "flow", APIName: "flow", Organization: flowdock.Organization{APIName: "org"}}}
+	notifications = NewNotifications(nil)
+	now := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	notifications.Add(NewNotification(now.Add(2*time.Hour), "alice", "t1", "flow", 11), "2", "t1")
+	notifications.Add(NewNotification(now.Add(time.Hour), "carol", "t2", "flow", 12), "2", "t2")
+	notifications.Add(NewNotification(now.Add(3*time.Hour), "alice", "t2", "flow", 13), "3", "t2")
+
+	want := "Pings waiting for you:\n\n" +
+		"| Target | Pinger | Due | Message |\n" +
+		"| --- | --- | --- | --- |\n" +
+		"| bob | carol | Wed 14 Oct 11:00 EEST | [here](https://www.flowdock.com/app/org/flow/messages/12) |\n" +
+		"| bob | alice | Wed 14 Oct 12:00 EEST | [here](https://www.flowdock.com/app/org/flow/messages/11) |\n"
+	if got := listCommand("bob", "2", "t9", ""); got != want {
+		t.Errorf("Wanted\n%s\ngot\n%s", want, got)
+	}
+
+	sent := listCommand("alice", "1", "t9", " sent")
+	if strings.Count(sent, "| alice |") != 2 || !strings.HasPrefix(sent, "Pings you have sent:") {
+		t.Errorf("Wanted the 2 pings alice sent, got\n%s", sent)
+	}
+	thread := listCommand("carol", "3", "t2", "thread")
+	if strings.Count(thread, "[here]") != 2 || strings.Contains(thread, "messages/11") {
+		t.Errorf("Wanted the 2 pings in t2, got\n%s", thread)
+	}
+	if got := listCommand("carol", "3", "t2", ""); strings.Count(got, "[here]") != 1 {
+		t.Errorf("Wanted the ping waiting for carol, got\n%s", got)
+	}
+	if got := listCommand("alice", "1", "t9", ""); got != "Pings waiting for you: none." {
+		t.Errorf("Wanted no pings, got %q", got)
+	}
+	if got := listCommand("alice", "1", "t9", "bogus"); !strings.HasPrefix(got, "Usage:") {
+		t.Errorf("Wanted usage, got %q", got)
+	}
+}
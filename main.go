@@ -6,11 +6,13 @@ import (
 	"io/ioutil"
 	"log"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gnyman/flowdock"
+	"github.com/jabbors/flowdock-notifybot/timeexpr"
 
 	yaml "gopkg.in/yaml.v2"
 )
@@ -19,68 +21,267 @@ type ThreadID int64
 type Username string
 
 type config struct {
-	FlowdockAPIKey string `yaml:"flowdock_api_key"`
-	StoragePath    string `yaml:"storage_path"`
-	Prefix         rune   `yaml:"ping_prefix"`
+	FlowdockAPIKey  string                `yaml:"flowdock_api_key"`
+	StoragePath     string                `yaml:"storage_path"`
+	SettingsPath    string                `yaml:"settings_path"`
+	Prefix          rune                  `yaml:"ping_prefix"`
+	Tiers           Tiers                 `yaml:"tiers"`
+	DefaultTimezone string                `yaml:"default_timezone"`
+	Holidays        map[string][]string   `yaml:"holidays"`
+	DefaultHolidays string                `yaml:"default_holidays"`
+	WorkingHours    WorkingHours          `yaml:"working_hours"`
+	WorkingDays     []string              `yaml:"working_days"`
+	Users           map[string]userConfig `yaml:"users"`
+	Admins          []string              `yaml:"admins"`
+	AwayStatuses    []string              `yaml:"away_statuses"`
+	Presence        Presence              `yaml:"presence"`
+	AckTags         []string              `yaml:"ack_tags"`
+	Clearing        Clearing              `yaml:"clearing"`
 }
 
-const (
-	fastDelay   = 1 * time.Hour
-	fasterDelay = 25 * time.Minute
-)
+// userConfig holds the settings of a single user given in the config file
+type userConfig struct {
+	Timezone     string       `yaml:"timezone"`
+	Holidays     string       `yaml:"holidays"`
+	WorkingHours WorkingHours `yaml:"working_hours"`
+	WorkingDays  []string     `yaml:"working_days"`
+}
 
 // Global variables
 var flowdockAPIKey = ""
 var notificationStorage = "/tmp/flowdock_notifications"
+var settingsStorage = "/tmp/flowdock_settings"
 var prefix = "!"
 var slowPrefix = "!"
-var fastPrefix = "!!"
-var fasterPrefix = "!!!"
-var notifications Notifications
+var tiers = defaultTiers
+var notifRegex *regexp.Regexp
+var notifications *Notifications
+var deliveries = NewDeliveries()
+var flows = make(map[string]flowdock.Flow)
+var helpMessage string
+var admins = make(map[string]bool)
 var users Users
+var userSettings = NewUserSettings()
+var userConfigs = make(map[string]userConfig)
+var defaultLocation = time.UTC
+var holidayCalendars = make(map[string]Holidays)
+var defaultHolidays = ""
+var defaultCalendar, _ = NewCalendar(WorkingHours{}, nil)
+var userCalendars = make(map[string]*Calendar)
+
+// locationFor returns the timezone of the user with nick. A timezone set with
+// the timezone command takes precedence over the one in the config file.
+func locationFor(nick string) *time.Location {
+	timezone := userSettings.Get(nick).Timezone
+	if timezone == "" {
+		timezone = userConfigs[strings.ToLower(nick)].Timezone
+	}
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err == nil {
+			return location
+		}
+		log.Printf("Could not load timezone '%s' of user %s, using default", timezone, nick)
+	}
+	return defaultLocation
+}
 
-// Return the next workday (not saturday or sunday) at 9 helsinki time
-func NextWorkdayAtNine() time.Time {
-	location, err := time.LoadLocation("Europe/Helsinki")
-	if err != nil {
-		log.Panic("Could not load timezone info")
+// holidaysFor returns the holiday calendar of the user with nick, or the
+// default calendar if the user has none configured
+func holidaysFor(nick string) Holidays {
+	name := userConfigs[strings.ToLower(nick)].Holidays
+	if name == "" {
+		name = defaultHolidays
 	}
-	now := time.Now().In(location).Truncate(time.Hour)
-	hoursFromNine := time.Duration(9 - now.Hour())
-	if hoursFromNine > 0 {
-		now = now.Add(hoursFromNine * time.Hour)
+	return holidayCalendars[name]
+}
+
+// calendarFor returns the business calendar of the user with nick in their
+// timezone and with their holidays
+func calendarFor(nick string) *Calendar {
+	calendar := *defaultCalendar
+	if userCalendar, ok := userCalendars[strings.ToLower(nick)]; ok {
+		calendar = *userCalendar
+	}
+	calendar.Location = locationFor(nick)
+	calendar.Holidays = holidaysFor(nick)
+	return &calendar
+}
+
+// createNotifyTimeAndTag returns the time when the notification for a ping
+// sent at now shall be sent and the tag used, or a zero time if no tier
+// matches the prefix
+func createNotifyTimeAndTag(prefix, username string, now time.Time) (time.Time, string) {
+	tier, found := tiers.Find(utf8.RuneCountInString(prefix))
+	if !found {
+		return time.Time{}, ""
+	}
+	t := tier.Time(now, calendarFor(username))
+	return t, fmt.Sprintf("%s-%v", tier.Tag, username)
+}
+
+// ping is a request for a notification found in a message
+type ping struct {
+	prefix string
+	nick   string
+	kind   string // @, + or / if an explicit delivery time is given
+	spec   string
+	rest   string // the text following the ping
+	self   bool   // the ping is a reminder for the author
+}
+
+// pingRegex returns the regex matching pings with the given prefix and an
+// optional explicit delivery time
+func pingRegex(prefix string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`(\%s+)([\wåäö]+)(?:([@+/])([\w:.@]*))?`, prefix))
+}
+
+// findPings returns the pings to known users in content written by author.
+// Pings to "me" are reminders for the author.
+func findPings(content, author string) []ping {
+	var pings []ping
+	for _, match := range notifRegex.FindAllStringSubmatchIndex(content, -1) {
+		field := func(i int) string {
+			if match[2*i] < 0 {
+				return ""
+			}
+			return content[match[2*i]:match[2*i+1]]
+		}
+		nick := strings.ToLower(field(2))
+		self := nick == "me" && author != ""
+		if self {
+			nick = strings.ToLower(author)
+		}
+		// Check first if the username is a known username, if not skip
+		if !users.Exists(nick) {
+			continue
+		}
+		pings = append(pings, ping{prefix: field(1), nick: nick, kind: field(3), spec: field(4), rest: content[match[1]:], self: self})
+	}
+	return pings
+}
+
+// maxReasonLength is the number of characters of a ping's reason kept
+const maxReasonLength = 80
+
+// quotedRegex matches a reason given in quotes
+var quotedRegex = regexp.MustCompile(`["“”]([^"“”]+)["“”]`)
+
+// strayPunctuationRegex matches punctuation left behind by removed pings
+var strayPunctuationRegex = regexp.MustCompile(`\s+([,.:;!?])`)
+
+// pingReason returns why p was sent in content at the given time: a quoted
+// text following the ping, or else the text surrounding the pings without the
+// delivery time, truncated to maxReasonLength characters
+func pingReason(p ping, content string, sent time.Time) string {
+	rest := p.rest
+	if loc := notifRegex.FindStringIndex(rest); loc != nil {
+		rest = rest[:loc[0]]
+	}
+	reason := ""
+	if match := quotedRegex.FindStringSubmatch(rest); match != nil {
+		reason = match[1]
 	} else {
-		now = now.Add((24 + hoursFromNine) * time.Hour)
+		if p.kind == "" {
+			consumed := 0
+			if _, n, ok := ParseRecurrence(p.rest, calendarFor(p.nick).Workdays); ok {
+				consumed = n
+			} else if _, n, ok := pingTimeExpr(p, sent.In(locationFor(p.nick))); ok {
+				consumed = n
+			}
+			start := len(content) - len(p.rest)
+			content = content[:start] + content[start+consumed:]
+		}
+		reason = notifRegex.ReplaceAllStringFunc(content, func(token string) string {
+			nick := strings.ToLower(notifRegex.FindStringSubmatch(token)[2])
+			if nick == "me" || users.Exists(nick) {
+				return ""
+			}
+			return token
+		})
+		reason = strayPunctuationRegex.ReplaceAllString(reason, "$1")
+		reason = strings.TrimLeft(reason, " ,:;-")
+	}
+	return truncate(strings.Join(strings.Fields(reason), " "), maxReasonLength)
+}
+
+// truncate shortens text to at most max characters, at a word boundary if
+// there is one, marking the cut with an ellipsis
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
 	}
-	switch now.Weekday() {
-	case time.Saturday:
-		now = now.AddDate(0, 0, 2)
-	case time.Sunday:
-		now = now.AddDate(0, 0, 1)
+	cut := string(runes[:max-1])
+	if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
 	}
-	return now
+	return strings.TrimRight(cut, " ,.;:") + "…"
 }
 
-// createNotifyTimeAndTag returns the time when the notification shall be sent
-// and the tag used
-func createNotifyTimeAndTag(prefix, username string, location *time.Location) (time.Time, string) {
-	var t time.Time
-	var tag string
+// pingTimeExpr parses the time expression following p relative to ref and
+// returns the time and the number of bytes of p.rest it spans. Only a single
+// prefix is followed by a time expression, a repeated one asks for its tier.
+func pingTimeExpr(p ping, ref time.Time) (time.Time, int, bool) {
+	if p.kind != "" || utf8.RuneCountInString(p.prefix) != 1 {
+		return time.Time{}, 0, false
+	}
+	return timeexpr.Parse(p.rest, ref)
+}
 
-	if prefix == fastPrefix {
-		t = time.Now().In(location).Add(fastDelay)
-		tag = fmt.Sprintf("notify-short-%v", username)
+// notifyTimeAndTag returns the time when the notification for p, sent at the
+// given time, shall be sent and the tag used. An explicit delivery time takes
+// precedence over a time expression following a single prefix, which in turn
+// takes precedence over the prefix.
+func notifyTimeAndTag(p ping, sent time.Time) (time.Time, string, error) {
+	location := locationFor(p.nick)
+	if p.kind == "" {
+		if t, _, ok := pingTimeExpr(p, sent.In(location)); ok {
+			return t, fmt.Sprintf("notify-at-%v", p.nick), nil
+		}
+		t, tag := createNotifyTimeAndTag(p.prefix, p.nick, sent)
+		return t, tag, nil
 	}
-	if prefix == slowPrefix {
-		t = NextWorkdayAtNine()
-		tag = fmt.Sprintf("notify-long-%v", username)
+	t, err := parseDeliveryTime(p.kind, p.spec, sent, location)
+	if err != nil {
+		return time.Time{}, "", err
 	}
-	if prefix == fasterPrefix {
-		t = time.Now().In(location).Add(fasterDelay)
-		tag = fmt.Sprintf("notify-shorter-%v", username)
+	return t, fmt.Sprintf("notify-at-%v", p.nick), nil
+}
+
+// sentTime returns the time of a Flowdock event timestamp given in
+// milliseconds, or the current time if the timestamp is missing
+func sentTime(timestamp int64) time.Time {
+	if timestamp == 0 {
+		return clock.Now()
 	}
+	return time.Unix(0, timestamp*int64(time.Millisecond))
+}
 
-	return t, tag
+// timezoneCommand handles the timezone command sent by the user with nick and
+// returns the reply to send back
+func timezoneCommand(nick, args string) string {
+	timezone := strings.TrimSpace(args)
+	if timezone == "" {
+		location := locationFor(nick)
+		return fmt.Sprintf("Your timezone is %s, the time there is now %s.", location, clock.Now().In(location).Format("Mon 15:04"))
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "Local" {
+		return fmt.Sprintf("Unknown timezone '%s', use a name like Europe/Helsinki or America/New_York.", timezone)
+	}
+	setting := userSettings.Get(nick)
+	setting.Timezone = location.String()
+	userSettings.Set(nick, setting)
+	err = userSettings.Save(settingsStorage)
+	if err != nil {
+		log.Println(err)
+	}
+	reply := fmt.Sprintf("Your timezone is now %s.", location)
+	if tier, found := tiers.Find(1); found {
+		reply += fmt.Sprintf(" A slow ping to you now would arrive %s.", tier.Time(clock.Now(), calendarFor(nick)).Format("Mon 15:04 MST"))
+	}
+	return reply
 }
 
 func main() {
@@ -104,10 +305,84 @@ func main() {
 	if conf.StoragePath != "" {
 		notificationStorage = conf.StoragePath
 	}
+	if conf.SettingsPath != "" {
+		settingsStorage = conf.SettingsPath
+	}
+	if conf.DefaultTimezone == "" {
+		conf.DefaultTimezone = "Europe/Helsinki"
+	}
+	defaultLocation, err = time.LoadLocation(conf.DefaultTimezone)
+	if err != nil {
+		log.Fatalf("Failed to load default timezone '%s': %v", conf.DefaultTimezone, err)
+	}
+	for name, files := range conf.Holidays {
+		holidayCalendars[name], err = LoadHolidays(files...)
+		if err != nil {
+			log.Fatalln("Failed to load holidays:", err)
+		}
+		log.Printf("Loaded %d holidays for calendar '%s'", len(holidayCalendars[name]), name)
+	}
+	if _, ok := holidayCalendars[conf.DefaultHolidays]; conf.DefaultHolidays != "" && !ok {
+		log.Fatalf("Unknown default holiday calendar '%s'", conf.DefaultHolidays)
+	}
+	defaultHolidays = conf.DefaultHolidays
+	defaultCalendar, err = NewCalendar(conf.WorkingHours, conf.WorkingDays)
+	if err != nil {
+		log.Fatalln("Invalid working hours:", err)
+	}
+	for nick, userConf := range conf.Users {
+		if _, ok := holidayCalendars[userConf.Holidays]; userConf.Holidays != "" && !ok {
+			log.Fatalf("Unknown holiday calendar '%s' of user %s", userConf.Holidays, nick)
+		}
+		if userConf.Timezone != "" {
+			_, err = time.LoadLocation(userConf.Timezone)
+			if err != nil {
+				log.Fatalf("Failed to load timezone '%s' of user %s: %v", userConf.Timezone, nick, err)
+			}
+		}
+		if userConf.WorkingHours != (WorkingHours{}) || len(userConf.WorkingDays) != 0 {
+			hours := userConf.WorkingHours
+			if hours == (WorkingHours{}) {
+				hours = conf.WorkingHours
+			}
+			days := userConf.WorkingDays
+			if len(days) == 0 {
+				days = conf.WorkingDays
+			}
+			userCalendars[strings.ToLower(nick)], err = NewCalendar(hours, days)
+			if err != nil {
+				log.Fatalf("Invalid working hours of user %s: %v", nick, err)
+			}
+		}
+		userConfigs[strings.ToLower(nick)] = userConf
+	}
+	presence = conf.Presence
+	if len(conf.AckTags) > 0 {
+		ackTags = normalizeTags(conf.AckTags)
+	}
+	if conf.Clearing.Default == "" {
+		conf.Clearing.Default = clearThread
+	}
+	if err := conf.Clearing.Validate(); err != nil {
+		log.Fatalln("Invalid clearing:", err)
+	}
+	clearing = conf.Clearing
+	awayStatuses, err = compileAwayStatuses(conf.AwayStatuses)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, nick := range conf.Admins {
+		admins[strings.ToLower(nick)] = true
+	}
 	if conf.Prefix != 0 {
 		slowPrefix = string(conf.Prefix)
-		fastPrefix = slowPrefix + slowPrefix
-		fasterPrefix = slowPrefix + fastPrefix
+	}
+	if conf.Tiers != nil {
+		tiers = conf.Tiers
+	}
+	err = tiers.Validate()
+	if err != nil {
+		log.Fatalln("Invalid tiers:", err)
 	}
 
 	// check that API key is given
@@ -115,9 +390,14 @@ func main() {
 		log.Fatal("An API key for Flowdock must be specified")
 	}
 
-	notifications := NewNotifications()
+	notifications = NewNotifications(scheduler)
 	restored, err := notifications.Restore(notificationStorage)
 	log.Printf("Restored %d notifations from file '%s'", restored, notificationStorage)
+	restored, err = userSettings.Restore(settingsStorage)
+	if err != nil {
+		log.Println(err)
+	}
+	log.Printf("Restored settings of %d users from file '%s'", restored, settingsStorage)
 
 	events := make(chan flowdock.Event)
 	c := flowdock.NewClient(flowdockAPIKey)
@@ -131,186 +411,57 @@ func main() {
 		users.Add(c.Users[userID].Nick, userID)
 	}
 	users.Print()
-
-	location, err := time.LoadLocation("Europe/Helsinki")
+	botUserID, err = fetchBotUserID(flowdockAPIKey)
 	if err != nil {
-		log.Panic("Could not load timeszone info")
+		log.Printf("Could not find out the user of the bot: %v", err)
 	}
 
 	// build regex for matching pings
-	notifRegex := regexp.MustCompile(fmt.Sprintf(`(\%s+)([\wåäö]+)`, prefix))
+	notifRegex = pingRegex(prefix)
 
-	helpMessage := "Notifybot does slow notifications."
-	helpMessage += " Create a slow notification for a person by doing " + slowPrefix + "<nick> or " + fastPrefix + "<nick> or " + fasterPrefix + "<nick>."
-	helpMessage += " The first will @<nick> the person the following day at 09:00 Finnish time."
-	helpMessage += " The others will notify <nick> after " + string(fastDelay) + " and " + string(fasterDelay) + " respectively."
-	helpMessage += " If the target is active in the thread, both all of notifications will be cleared."
+	helpMessage = "Notifybot does slow notifications."
+	helpMessage += " Create a slow notification for a person by doing"
+	for i, tier := range tiers {
+		if i > 0 {
+			helpMessage += ","
+		}
+		helpMessage += fmt.Sprintf(" %s<nick> to @<nick> them %s", strings.Repeat(slowPrefix, tier.Repeat), tier.Describe())
+	}
+	helpMessage += "."
+	helpMessage += " " + clearingHelp()
+	helpMessage += " " + deliveryTimeHelp()
+	helpMessage += fmt.Sprintf(" You can also say it in words after a single prefix, e.g. %[1]s<nick> tomorrow afternoon, %[1]s<nick> next tuesday, %[1]s<nick> in 3 days or %[1]s<nick> end of day.", slowPrefix)
+	helpMessage += fmt.Sprintf(" The text around the ping, or a reason in quotes like %[1]s<nick> \"review PR 42\", is included in the delivered ping.", slowPrefix)
+	helpMessage += " Remind yourself by using me as the <nick>, your own activity does not clear reminders."
+	helpMessage += fmt.Sprintf(" See pending pings with %[1]slist (waiting for you), %[1]slist sent (sent by you) and %[1]slist thread (in this thread).", prefix)
+	helpMessage += fmt.Sprintf(" Withdraw your pings with %[1]scancel <nick> in the same thread or %[1]scancel all, or by removing the notify tag of the nick from your message.", prefix)
+	var acks []string
+	for tag := range ackTags {
+		acks = append(acks, "#"+tag)
+	}
+	sort.Strings(acks)
+	helpMessage += fmt.Sprintf(" Acknowledge a ping to you by tagging the pinging message %s.", strings.Join(acks, " or "))
+	helpMessage += " Snooze a ping delivered to you with " + prefix + "snooze [duration|tomorrow] in the same thread."
+	helpMessage += fmt.Sprintf(" Create a recurring ping with %[1]s<nick> every monday 10:00, list and cancel them with %[2]srecurring.", slowPrefix, prefix)
+	helpMessage += fmt.Sprintf(" Going on holiday? %[1]saway until 2026-11-02 defers pings to you until you are back, %[1]saway clear ends it and %[1]saway list shows who is away.", prefix)
+	helpMessage += " Set your timezone with " + prefix + "timezone <zone>, e.g. " + prefix + "timezone America/New_York."
 
-	flows := make(map[string]flowdock.Flow)
 	for _, flow := range c.AvailableFlows {
 		flows[flow.ID] = flow
 	}
+	for userID, user := range c.Users {
+		statuses[userID] = user.Status
+		if user.LastActivityTimestamp != 0 {
+			lastActivity[userID] = sentTime(user.LastActivityTimestamp)
+		}
+	}
 
-	//go ticker(&notifications)
-	ticker := time.NewTicker(5 * time.Second)
 	for {
 		select {
-		case <-ticker.C:
-			//log.Println("Tick, notifications contains %v", notifications)
-			for userID, notifs := range notifications {
-				if len(notifs) != 0 {
-					log.Printf("UserID: %v has notifications %v\n", userID, notifs)
-					for threadID, notif := range notifs {
-						if time.Now().In(location).After(notif.Timestamp) {
-							log.Printf("Sending notification due to no activity, %s after %s", notif.Timestamp, time.Now())
-							pingUser := c.Users[userID].Nick
-							message := fmt.Sprintf("@%v, slow ping from %v from [here](https://www.flowdock.com/app/walkbase/%s/messages/%d)", pingUser, strings.Title(notif.Pinger), flows[notif.Flow].APIName, notif.MessageID)
-							var body []byte
-							var err error
-							if notif.Thread != "" {
-								body, err = flowdock.SendMessageToFlowWithApiKey(flowdockAPIKey, notif.Flow, notif.Thread, message)
-							}
-							if err != nil {
-								log.Panic(err)
-							}
-							log.Printf("%v\n", string(body))
-							notifications.Delete(userID, threadID)
-							notifications.Save(notificationStorage)
-						}
-					}
-				}
-			}
+		case <-scheduler.Wake():
+			deliverDueNotifications(clock.Now())
 		case event := <-events:
 			switch event := event.(type) {
-			case flowdock.MessageEvent:
-				log.Printf("Message event %v", event)
-				orgNflow := strings.Split(event.Flow, ":")
-				var org string
-				var flow string
-				if len(orgNflow) != 2 {
-					if _, ok := flows[event.Flow]; ok {
-						org = flows[event.Flow].Organization.APIName
-						flow = flows[event.Flow].APIName
-					} else {
-						log.Printf("Odd, we got a message from a flow we do not know, maybe we joined a new channel, reconnecting")
-						break
-					}
-				} else {
-					org = orgNflow[0]
-					flow = orgNflow[1]
-				}
-
-				if _, found := notifications[event.UserID][event.ThreadID]; found {
-					log.Printf("User %v was active in thread %v for which he had a notificating pending, clearing notification", event.UserID, event.ThreadID)
-					notifications.Delete(event.UserID, event.ThreadID)
-					nickClear := fmt.Sprintf("cleared-%s", c.Users[event.UserID].Nick)
-					flowdock.EditMessageInFlowWithApiKey(flowdockAPIKey, org, flow, strconv.FormatInt(event.ID, 10), "", []string{nickClear})
-				}
-
-				if strings.HasPrefix(event.Content, prefix+"help") {
-					flowdock.SendMessageToFlowWithApiKey(flowdockAPIKey, event.Flow, event.ThreadID, helpMessage)
-				}
-
-				for _, field := range notifRegex.FindAllStringSubmatch(event.Content, -1) {
-					if len(field) < 2 {
-						continue
-					}
-					possiblePrefix := field[1]
-					possibleUsername := strings.ToLower(field[2])
-					// Check first if the username is a known username, if not skip
-					if !users.Exists(possibleUsername) {
-						continue
-					}
-					pinger := c.Users[event.UserID].Nick
-
-					notifyTime, notifyTag := createNotifyTimeAndTag(possiblePrefix, possibleUsername, location)
-					if !notifyTime.IsZero() {
-						log.Printf("%s requested notification for %s at %v", pinger, possibleUsername, notifyTime)
-						if users.Exists(possibleUsername) {
-							notification := NewNotification(notifyTime, pinger, event.ThreadID, event.Flow, event.ID)
-							notifications.Add(notification, users[possibleUsername], event.ThreadID)
-							flowdock.EditMessageInFlowWithApiKey(flowdockAPIKey, org, flow, strconv.FormatInt(event.ID, 10), "", []string{notifyTag})
-							notifications.Save(notificationStorage)
-						} else {
-							log.Printf("User '%s' does not exists", possibleUsername)
-						}
-					} else {
-						log.Println("No time was set for notification")
-					}
-				}
-				log.Printf("%s said (%s): '%s'", c.DetailsForUser(event.UserID).Nick, event.Flow, event.Content)
-			case flowdock.CommentEvent:
-				log.Println("Comment event")
-				orgNflow := strings.Split(event.Flow, ":")
-				var org string
-				var flow string
-				if len(orgNflow) != 2 {
-					if _, ok := flows[event.Flow]; ok {
-						org = flows[event.Flow].Organization.APIName
-						flow = flows[event.Flow].APIName
-					} else {
-						log.Printf("Odd, we got a message from a flow we do not know, maybe we joined a new channel, reconnecting")
-						break
-					}
-				} else {
-					org = orgNflow[0]
-					flow = orgNflow[1]
-				}
-
-				log.Printf("%s commented (%s): '%s'", c.DetailsForUser(event.UserID).Nick, event.Flow, event.Content.Text)
-
-				var messageID string
-
-				for _, tag := range event.Tags {
-					if strings.HasPrefix(tag, "influx:") {
-						messageID = strings.TrimPrefix(tag, "influx:")
-					}
-				}
-
-				if _, found := notifications[event.UserID][messageID]; found {
-					log.Printf("User %v was active in comment thread %v for which he had a notificating pending, clearing notification", event.UserID, messageID)
-					notifications.Delete(event.UserID, messageID)
-					nickClear := fmt.Sprintf("cleared-%s", c.Users[event.UserID].Nick)
-					flowdock.EditMessageInFlowWithApiKey(flowdockAPIKey, org, flow, strconv.FormatInt(event.ID, 10), "", []string{nickClear})
-				}
-
-				if strings.HasPrefix(event.Content.Text, prefix+"help") {
-					flowdock.SendCommentToFlowWithApiKey(flowdockAPIKey, event.Flow, messageID, helpMessage)
-				}
-
-				for _, field := range notifRegex.FindAllStringSubmatch(event.Content.Text, -1) {
-					if len(field) < 2 {
-						continue
-					}
-					possiblePrefix := field[1]
-					possibleUsername := strings.ToLower(field[2])
-					// Check first if the username is a known username, if not skip
-					if !users.Exists(possibleUsername) {
-						continue
-					}
-					pinger := c.Users[event.UserID].Nick
-
-					notifyTime, notifyTag := createNotifyTimeAndTag(possiblePrefix, possibleUsername, location)
-					if !notifyTime.IsZero() {
-						log.Printf("%s requested notification for %s at %v", pinger, possibleUsername, notifyTime)
-						if users.Exists(possibleUsername) {
-							notification := NewNotification(notifyTime, pinger, messageID, event.Flow, event.ID)
-							notifications.Add(notification, users[possibleUsername], event.Flow)
-							flowdock.EditMessageInFlowWithApiKey(flowdockAPIKey, org, flow, strconv.FormatInt(event.ID, 10), "", []string{notifyTag})
-							notifications.Save(notificationStorage)
-						} else {
-							log.Printf("User '%s' does not exists", possibleUsername)
-						}
-					} else {
-						log.Println("No time was set for notification")
-					}
-				}
-
-				//		case flowdock.MessageEditEvent:
-				//			log.Printf("Looks like @%s just updated their previous message: '%s'. New message is '%s'", c.DetailsForUser(event.UserID).Nick, messageStore[event.Content.MessageID], event.Content.UpdatedMessage)
-			case flowdock.UserActivityEvent:
-				log.Printf("User activity event %v", event)
-				continue // Especially with > 10 people in your org, you will get MANY of these events.
 			case flowdock.ActionEvent:
 				log.Printf("Action event %v", event)
 				// If we get a flow-change, reload flows and users
@@ -328,7 +479,9 @@ func main() {
 					}
 					for userID, _ := range c.Users {
 						users.Add(c.Users[userID].Nick, userID)
+						setStatus(userID, c.Users[userID].Status, clock.Now())
 					}
+					notifications.Save(notificationStorage)
 				}
 			case nil:
 				c = flowdock.NewClient(flowdockAPIKey)
@@ -338,7 +491,7 @@ func main() {
 					time.Sleep(15 * time.Second)
 				}
 			default:
-				log.Printf("New event of type %T", event)
+				handleEvent(event)
 			}
 		}
 	}
@@ -1,14 +1,45 @@
 package main
 
 import (
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNextWorkdayNine(t *testing.T) {
-	nextWorkDayAtNine := NextWorkdayAtNine()
-	hour, min, sec := nextWorkDayAtNine.Clock()
-	if hour != 9 && min != 0 && sec != 0 {
-		t.Error("Expected NextWorkdayAtNine to be at 9:00:00")
+	// Friday
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := map[string]int{"Europe/Helsinki": 19, "America/New_York": 16, "Asia/Kolkata": 19}
+	for timezone, day := range tests {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		calendar, _ := NewCalendar(WorkingHours{}, nil)
+		calendar.Location = location
+		nextWorkDayAtNine := calendar.NextWorkdayAt(now, 9, 0)
+		if want := time.Date(2026, 10, day, 9, 0, 0, 0, location); !nextWorkDayAtNine.Equal(want) {
+			t.Errorf("Expected NextWorkdayAt to be %v in %s, got %v", want, timezone, nextWorkDayAtNine)
+		}
+	}
+}
+
+func TestLocationFor(t *testing.T) {
+	defaultLocation, _ = time.LoadLocation("Europe/Helsinki")
+	userConfigs = map[string]userConfig{"bob": {Timezone: "America/New_York"}}
+	userSettings = NewUserSettings()
+	userSettings.Set("Alice", UserSetting{Timezone: "Asia/Tokyo"})
+
+	tests := map[string]string{
+		"alice": "Asia/Tokyo",
+		"Bob":   "America/New_York",
+		"carol": "Europe/Helsinki",
+	}
+	for nick, want := range tests {
+		if got := locationFor(nick).String(); got != want {
+			t.Errorf("locationFor(%s): wanted %s, got %s", nick, want, got)
+		}
 	}
 }
 
@@ -38,3 +69,164 @@ func TestNextWorkdayNine(t *testing.T) {
 	}
 
 }*/
+
+func TestFindPings(t *testing.T) {
+	notifRegex = pingRegex("!")
+	users = NewUsers()
+	users.Add("Bob", "1")
+	users.Add("alice", "2")
+
+	pings := findPings("!Bob@14:30 and !!alice+2h, also !!!bob/mon@9. !carol does not exist, !alice", "alice")
+	want := []ping{
+		{prefix: "!", nick: "bob", kind: "@", spec: "14:30", rest: " and !!alice+2h, also !!!bob/mon@9. !carol does not exist, !alice"},
+		{prefix: "!!", nick: "alice", kind: "+", spec: "2h", rest: ", also !!!bob/mon@9. !carol does not exist, !alice"},
+		{prefix: "!!!", nick: "bob", kind: "/", spec: "mon@9.", rest: " !carol does not exist, !alice"},
+		{prefix: "!", nick: "alice", rest: ""},
+	}
+	if !reflect.DeepEqual(pings, want) {
+		t.Errorf("wanted %+v", want)
+		t.Errorf("got %+v", pings)
+	}
+}
+
+func TestNotifyTimeAndTagWithTimeExpression(t *testing.T) {
+	notifRegex = pingRegex("!")
+	users = NewUsers()
+	users.Add("bob", "1")
+	userConfigs = map[string]userConfig{"bob": {Timezone: "America/New_York"}}
+	userSettings = NewUserSettings()
+
+	newYork, _ := time.LoadLocation("America/New_York")
+	sent := time.Date(2026, 10, 14, 20, 0, 0, 0, time.UTC)
+
+	pings := findPings("!bob tomorrow afternoon please", "alice")
+	if len(pings) != 1 {
+		t.Fatalf("Wanted 1 ping, got %d", len(pings))
+	}
+	notifyTime, notifyTag, err := notifyTimeAndTag(pings[0], sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 10, 15, 13, 0, 0, 0, newYork); !notifyTime.Equal(want) {
+		t.Errorf("Wanted %v, got %v", want, notifyTime)
+	}
+	if notifyTag != "notify-at-bob" {
+		t.Errorf("Wanted tag notify-at-bob, got %s", notifyTag)
+	}
+}
+
+func TestNotifyTimeAndTagIncidentalWords(t *testing.T) {
+	notifRegex = pingRegex("!")
+	users = NewUsers()
+	users.Add("bob", "1")
+	userConfigs = make(map[string]userConfig)
+	userSettings = NewUserSettings()
+	tiers = defaultTiers
+	defaultLocation = time.UTC
+
+	sent := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		content string
+		want    time.Time
+		tag     string
+		reason  string
+	}{
+		{"!!bob next week's release notes need review", sent.Add(time.Hour), "notify-short-bob", "next week's release notes need review"},
+		{"!!!bob tomorrow the deploy", sent.Add(25 * time.Minute), "notify-shorter-bob", "tomorrow the deploy"},
+		{"!bob tonight's build is red", time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC), "notify-long-bob", "tonight's build is red"},
+	}
+	for _, test := range tests {
+		pings := findPings(test.content, "alice")
+		if len(pings) != 1 {
+			t.Fatalf("%q: wanted 1 ping, got %d", test.content, len(pings))
+		}
+		notifyTime, notifyTag, err := notifyTimeAndTag(pings[0], sent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !notifyTime.Equal(test.want) || notifyTag != test.tag {
+			t.Errorf("%q: wanted %v %s, got %v %s", test.content, test.want, test.tag, notifyTime, notifyTag)
+		}
+		if reason := pingReason(pings[0], test.content, sent); reason != test.reason {
+			t.Errorf("%q: wanted reason %q, got %q", test.content, test.reason, reason)
+		}
+	}
+}
+
+func TestFindPingsForMe(t *testing.T) {
+	notifRegex = pingRegex("!")
+	users = NewUsers()
+	users.Add("Bob", "1")
+
+	pings := findPings("!!me in 2 hours and !bob", "Bob")
+	want := []ping{
+		{prefix: "!!", nick: "bob", rest: " in 2 hours and !bob", self: true},
+		{prefix: "!", nick: "bob", rest: ""},
+	}
+	if !reflect.DeepEqual(pings, want) {
+		t.Errorf("wanted %+v", want)
+		t.Errorf("got %+v", pings)
+	}
+
+	if pings := findPings("!me", ""); len(pings) != 0 {
+		t.Errorf("Expected no pings without an author, got %+v", pings)
+	}
+}
+
+func TestPingReason(t *testing.T) {
+	notifRegex = pingRegex("!")
+	users = NewUsers()
+	users.Add("bob", "1")
+	users.Add("alice", "2")
+	userConfigs = make(map[string]userConfig)
+	userSettings = NewUserSettings()
+
+	tests := map[string]string{
+		"!bob can you review this?":                     "can you review this?",
+		"hey !bob, the build is red":                    "hey, the build is red",
+		`!bob "review PR 42" when you have time`:        "review PR 42",
+		`!bob tomorrow “deploy”`:                        "deploy",
+		"!bob tomorrow afternoon please check the logs": "please check the logs",
+		"!bob@14:30 standup notes":                      "standup notes",
+		"!bob !!alice lunch?":                           "lunch?",
+		"!bob !important stuff":                         "!important stuff",
+		"!bob":                                          "",
+		"!bob " + strings.Repeat("word ", 30):           strings.TrimSpace(strings.Repeat("word ", 15)) + "…",
+	}
+	for content, want := range tests {
+		pings := findPings(content, "alice")
+		if len(pings) == 0 {
+			t.Errorf("findPings(%q): no pings", content)
+			continue
+		}
+		if got := pingReason(pings[0], content, time.Now()); got != want {
+			t.Errorf("pingReason(%q): wanted %q, got %q", content, want, got)
+		}
+	}
+}
+
+func TestPingReasonAtSentTime(t *testing.T) {
+	notifRegex = pingRegex("!")
+	users = NewUsers()
+	users.Add("bob", "1")
+	userConfigs = map[string]userConfig{"bob": {Timezone: "America/New_York"}}
+	userSettings = NewUserSettings()
+	// By the time the message is handled it is past 16 in UTC, but not for
+	// bob when it was sent
+	oldClock := clock
+	clock = &fakeClock{now: time.Date(2026, 10, 14, 17, 0, 0, 0, time.UTC)}
+	defer func() { clock = oldClock }()
+	sent := time.Date(2026, 10, 14, 15, 0, 0, 0, time.UTC)
+
+	content := "!bob today at 16 deploy"
+	pings := findPings(content, "alice")
+	if len(pings) != 1 {
+		t.Fatalf("Wanted 1 ping, got %d", len(pings))
+	}
+	if _, tag, _ := notifyTimeAndTag(pings[0], sent); tag != "notify-at-bob" {
+		t.Errorf("Wanted the time expression to be used, got tag %s", tag)
+	}
+	if reason := pingReason(pings[0], content, sent); reason != "deploy" {
+		t.Errorf("Wanted reason %q, got %q", "deploy", reason)
+	}
+}
@@ -11,43 +11,98 @@ import (
 
 // Notification holds information about a notification
 type Notification struct {
-	Timestamp time.Time
-	Thread    string
-	Flow      string
-	Pinger    string
-	MessageID int64
+	Timestamp  time.Time
+	Thread     string
+	Flow       string
+	Parent     string // the message commented on, for pings made in comments
+	Pinger     string
+	MessageID  int64
+	Reason     string // why the pinger pinged, from the text of the ping
+	Recurrence Recurrence
+	Self       bool // a reminder the pinger set for themselves
+	Tier       int  // the tier of the ping, by prefix repetitions
+	Held       bool // held back while the status of the target says away
+
+	// WaitingSince is when the notification started waiting for the target
+	// to show activity
+	WaitingSince time.Time
+
+	// Escalation state of a delivered notification
+	DeliveredAt time.Time
+	Escalated   int // number of escalation steps taken
 }
 
 // NewNotification creates a new notification from the given parameters
 func NewNotification(t time.Time, pinger, threadID, flowID string, messageID int64) Notification {
-	return Notification{t, threadID, flowID, pinger, messageID}
+	return Notification{Timestamp: t, Thread: threadID, Flow: flowID, Pinger: pinger, MessageID: messageID}
 }
 
-// Notifications is a map of Notifications by user and thread ID
-type Notifications map[string]map[string]Notification
+// Conversation returns the conversation the notification was created in
+func (n Notification) Conversation() Conversation {
+	return Conversation{Flow: n.Flow, Thread: n.Thread, Parent: n.Parent}
+}
+
+// ClearedByActivity returns true if the notification is cleared when the
+// target is active in the thread. Recurring notifications and reminders users
+// set for themselves are not.
+func (n Notification) ClearedByActivity() bool {
+	return n.Recurrence.IsZero() && !n.Self
+}
 
-// NewNotifications returns a empty notifications map
-func NewNotifications() Notifications {
-	return make(map[string]map[string]Notification)
+// Notifications stores the pending notifications by user and thread ID and
+// keeps their deliveries scheduled. A user may have several notifications in a
+// thread, one per source message.
+type Notifications struct {
+	ByUser    map[string]map[string][]Notification // by user ID and conversation key
+	scheduler *Scheduler                           // schedules deliveries, if set
 }
 
-// Restore restores saved notifications from file
-func (n Notifications) Restore(file string) (int, error) {
+// legacyNotifications is the format notifications were saved in when a user
+// had at most one notification per thread
+type legacyNotifications map[string]map[string]Notification
+
+// NewNotifications returns an empty notification store whose deliveries are
+// scheduled with scheduler, or not at all if it is nil
+func NewNotifications(scheduler *Scheduler) *Notifications {
+	return &Notifications{ByUser: make(map[string]map[string][]Notification), scheduler: scheduler}
+}
+
+// Restore restores saved notifications from file, also from the legacy
+// format with one notification per thread, and schedules them
+func (n *Notifications) Restore(file string) (int, error) {
 	if _, err := os.Stat(file); err == nil {
 		rawData, err := ioutil.ReadFile(file)
 		if err != nil {
 			return 0, fmt.Errorf("Error could not restore notifications because could not read file :-(")
 		}
-		buffer := bytes.NewBuffer(rawData)
-		dec := gob.NewDecoder(buffer)
-
-		err = dec.Decode(&n)
+		dec := gob.NewDecoder(bytes.NewBuffer(rawData))
+		err = dec.Decode(&n.ByUser)
 		if err != nil {
-			return 0, fmt.Errorf("Error could not decode %v", dec)
+			legacy := make(legacyNotifications)
+			dec = gob.NewDecoder(bytes.NewBuffer(rawData))
+			if err := dec.Decode(&legacy); err != nil {
+				return 0, fmt.Errorf("Error could not decode %v", dec)
+			}
+			// Legacy notifications are stored by thread ID rather than
+			// by conversation
+			for to, notifs := range legacy {
+				for _, notif := range notifs {
+					if _, exists := n.ByUser[to]; !exists {
+						n.ByUser[to] = make(map[string][]Notification)
+					}
+					key := notif.Conversation().Key()
+					n.ByUser[to][key] = append(n.ByUser[to][key], notif)
+				}
+			}
 		}
 		total := 0
-		for _, user := range n {
-			total += len(user)
+		for _, user := range n.ByUser {
+			for _, notifs := range user {
+				total += len(notifs)
+			}
+		}
+		if n.scheduler != nil {
+			n.scheduler.Load(n)
 		}
 		return total, nil
 	}
@@ -55,10 +110,10 @@ func (n Notifications) Restore(file string) (int, error) {
 }
 
 // Save saves notifications to file
-func (n Notifications) Save(file string) error {
+func (n *Notifications) Save(file string) error {
 	var buffer bytes.Buffer
 	enc := gob.NewEncoder(&buffer)
-	err := enc.Encode(n)
+	err := enc.Encode(n.ByUser)
 	if err != nil {
 		return fmt.Errorf("Error could not save the notifications")
 	}
@@ -67,15 +122,105 @@ func (n Notifications) Save(file string) error {
 	return nil
 }
 
-// Add adds a notification to the map
-func (n Notifications) Add(nn Notification, to, threadID string) {
-	if _, exists := n[to]; !exists {
-		n[to] = make(map[string]Notification)
+// Add adds a notification to the map, replacing the one from the same source
+// message, and schedules the thread
+func (n *Notifications) Add(nn Notification, to, threadID string) {
+	if _, exists := n.ByUser[to]; !exists {
+		n.ByUser[to] = make(map[string][]Notification)
+	}
+	for i, notif := range n.ByUser[to][threadID] {
+		if notif.MessageID == nn.MessageID {
+			n.ByUser[to][threadID][i] = nn
+			n.schedule(to, threadID)
+			return
+		}
+	}
+	n.ByUser[to][threadID] = append(n.ByUser[to][threadID], nn)
+	n.schedule(to, threadID)
+}
+
+// Remove removes the notification from the same source message as nn
+func (n *Notifications) Remove(nn Notification, to, threadID string) {
+	notifs := n.ByUser[to][threadID]
+	for i, notif := range notifs {
+		if notif.MessageID == nn.MessageID {
+			n.ByUser[to][threadID] = append(notifs[:i:i], notifs[i+1:]...)
+			break
+		}
+	}
+	if len(n.ByUser[to][threadID]) == 0 {
+		delete(n.ByUser[to], threadID)
+	}
+	n.schedule(to, threadID)
+}
+
+// Delete deletes all notifications of a user in a thread
+func (n *Notifications) Delete(to, threadID string) {
+	delete(n.ByUser[to], threadID)
+	n.schedule(to, threadID)
+}
+
+// Clear deletes the notifications of a user that are cleared by their
+// activity and match, and returns them
+func (n *Notifications) Clear(to string, match func(threadID string, notif Notification) bool) []Notification {
+	var cleared []Notification
+	for threadID, notifs := range n.ByUser[to] {
+		var kept []Notification
+		for _, notif := range notifs {
+			if notif.ClearedByActivity() && match(threadID, notif) {
+				cleared = append(cleared, notif)
+			} else {
+				kept = append(kept, notif)
+			}
+		}
+		if len(kept) == len(notifs) {
+			continue
+		}
+		if len(kept) == 0 {
+			delete(n.ByUser[to], threadID)
+		} else {
+			n.ByUser[to][threadID] = kept
+		}
+		n.schedule(to, threadID)
+	}
+	return cleared
+}
+
+// Due returns the notifications of a user in a thread that are due at now
+func (n *Notifications) Due(to, threadID string, now time.Time) []Notification {
+	var due []Notification
+	for _, notif := range n.ByUser[to][threadID] {
+		if !notif.Timestamp.After(now) {
+			due = append(due, notif)
+		}
 	}
-	n[to][threadID] = nn
+	return due
 }
 
-// Delete deletes a notification from the map
-func (n Notifications) Delete(to, threadID string) {
-	delete(n[to], threadID)
+// next returns the time of the earliest notification of a user in a thread,
+// false if there are none
+func (n *Notifications) next(to, threadID string) (time.Time, bool) {
+	notifs := n.ByUser[to][threadID]
+	if len(notifs) == 0 {
+		return time.Time{}, false
+	}
+	next := notifs[0].Timestamp
+	for _, notif := range notifs[1:] {
+		if notif.Timestamp.Before(next) {
+			next = notif.Timestamp
+		}
+	}
+	return next, true
+}
+
+// schedule schedules a thread of a user at its earliest notification
+func (n *Notifications) schedule(to, threadID string) {
+	if n.scheduler == nil {
+		return
+	}
+	if next, ok := n.next(to, threadID); ok {
+		n.scheduler.Schedule(to, threadID, next)
+	} else {
+		n.scheduler.Cancel(to, threadID)
+	}
 }
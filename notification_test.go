@@ -1,15 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"testing"
 	"time"
 )
 
 func TestNotificationsAdd(t *testing.T) {
-	notifications := NewNotifications()
+	notifications := NewNotifications(nil)
 
-	if len(notifications) != 0 {
+	if len(notifications.ByUser) != 0 {
 		t.Errorf("Add: len(notifcations) is not empty")
 	}
 
@@ -18,17 +22,17 @@ func TestNotificationsAdd(t *testing.T) {
 	notifications.Add(notification, "user1", "thread2")
 	notifications.Add(notification, "user2", "thread3")
 
-	if len(notifications["user1"]) != 2 && len(notifications["user2"]) != 1 {
+	if len(notifications.ByUser["user1"]) != 2 && len(notifications.ByUser["user2"]) != 1 {
 		t.Errorf("Add: notifications are missing")
 	}
 
-	if notifications["user1"]["thread1"] != notification {
+	if notifications.ByUser["user1"]["thread1"][0] != notification {
 		t.Errorf("Notification not found in map as expected")
 	}
 }
 
 func TestNotificationsDelete(t *testing.T) {
-	notifications := NewNotifications()
+	notifications := NewNotifications(nil)
 
 	notification := NewNotification(time.Now(), "pinger", "threadID", "flowID", 0)
 	notifications.Add(notification, "user1", "thread1")
@@ -37,15 +41,15 @@ func TestNotificationsDelete(t *testing.T) {
 
 	notifications.Delete("user1", "thread2")
 
-	if len(notifications["user1"]) != 1 && len(notifications["user2"]) != 1 {
+	if len(notifications.ByUser["user1"]) != 1 && len(notifications.ByUser["user2"]) != 1 {
 		t.Errorf("Add: notifications are missing")
 	}
 }
 
 func TestNotificationsStoreAndRestore(t *testing.T) {
-	notifications := NewNotifications()
+	notifications := NewNotifications(nil)
 
-	notification := NewNotification(time.Now(), "pinger", "threadID", "flowID", 0)
+	notification := NewNotification(time.Now().Round(0), "pinger", "threadID", "flowID", 0)
 	notifications.Add(notification, "user1", "thread1")
 	notifications.Add(notification, "user1", "thread2")
 	notifications.Add(notification, "user2", "thread3")
@@ -56,7 +60,7 @@ func TestNotificationsStoreAndRestore(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	restoredNotifications := NewNotifications()
+	restoredNotifications := NewNotifications(nil)
 	restored, err := restoredNotifications.Restore(file)
 	if err != nil {
 		t.Fatal(err)
@@ -70,3 +74,108 @@ func TestNotificationsStoreAndRestore(t *testing.T) {
 		t.Errorf("got %+v", restoredNotifications)
 	}
 }
+
+func TestNotificationClearedByActivity(t *testing.T) {
+	notification := NewNotification(time.Now(), "pinger", "threadID", "flowID", 0)
+	if !notification.ClearedByActivity() {
+		t.Error("Expected a ping to be cleared by activity")
+	}
+	notification.Self = true
+	if notification.ClearedByActivity() {
+		t.Error("Expected a reminder to self not to be cleared by activity")
+	}
+}
+
+func TestNotificationsSeveralPerThread(t *testing.T) {
+	notifications := NewNotifications(nil)
+	now := time.Now()
+
+	alice := NewNotification(now.Add(time.Hour), "alice", "thread1", "flowID", 1)
+	bob := NewNotification(now.Add(25*time.Minute), "bob", "thread1", "flowID", 2)
+	reminder := NewNotification(now.Add(2*time.Hour), "carol", "thread1", "flowID", 3)
+	reminder.Self = true
+	notifications.Add(alice, "carol", "thread1")
+	notifications.Add(bob, "carol", "thread1")
+	notifications.Add(reminder, "carol", "thread1")
+	if len(notifications.ByUser["carol"]["thread1"]) != 3 {
+		t.Fatalf("Wanted 3 notifications in the thread, got %+v", notifications.ByUser["carol"]["thread1"])
+	}
+	if next, _ := notifications.next("carol", "thread1"); !next.Equal(bob.Timestamp) {
+		t.Errorf("Wanted the thread due at %v, got %v", bob.Timestamp, next)
+	}
+
+	alice.Tier = 2
+	notifications.Add(alice, "carol", "thread1")
+	if got := notifications.ByUser["carol"]["thread1"]; len(got) != 3 || got[0].Tier != 2 {
+		t.Errorf("Wanted the notification of alice replaced, got %+v", got)
+	}
+	if due := notifications.Due("carol", "thread1", now.Add(time.Hour)); len(due) != 2 {
+		t.Errorf("Wanted 2 due, got %+v", due)
+	}
+
+	notifications.Remove(bob, "carol", "thread1")
+	if got := notifications.ByUser["carol"]["thread1"]; len(got) != 2 || got[0].Pinger != "alice" || got[1].Pinger != "carol" {
+		t.Errorf("Wanted the notification of bob removed, got %+v", got)
+	}
+	if cleared := notifications.Clear("carol", func(threadID string, notif Notification) bool { return threadID == "thread1" }); len(cleared) != 1 || cleared[0].Pinger != "alice" {
+		t.Errorf("Wanted the notification of alice cleared, got %+v", cleared)
+	}
+	if got := notifications.ByUser["carol"]["thread1"]; len(got) != 1 || !got[0].Self {
+		t.Errorf("Wanted the reminder kept, got %+v", got)
+	}
+}
+
+func TestNotificationsRestoreLegacy(t *testing.T) {
+	notification1 := NewNotification(time.Now().Round(0), "pinger", "thread1", "flowID", 1)
+	notification2 := NewNotification(time.Now().Round(0), "pinger", "thread2", "flowID", 2)
+	legacy := legacyNotifications{"user1": {"thread1": notification1, "thread2": notification2}}
+
+	file := "/tmp/test-flowdock-legacy-notifications.gob"
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(legacy); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, buffer.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file)
+
+	restoredNotifications := NewNotifications(nil)
+	restored, err := restoredNotifications.Restore(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string][]Notification{"user1": {"flowID/thread1": {notification1}, "flowID/thread2": {notification2}}}
+	if restored != 2 || !reflect.DeepEqual(restoredNotifications.ByUser, want) {
+		t.Errorf("Wanted %+v, got %d %+v", want, restored, restoredNotifications)
+	}
+}
+
+func TestNotificationsSchedule(t *testing.T) {
+	s := NewScheduler()
+	notifications := NewNotifications(s)
+	notification := NewNotification(time.Now().Add(time.Hour), "pinger", "thread1", "flowID", 1)
+	notifications.Add(notification, "user1", "thread1")
+	if s.Len() != 1 {
+		t.Errorf("Wanted the thread scheduled, got %d", s.Len())
+	}
+
+	file := "/tmp/test-flowdock-scheduled-notifications.gob"
+	defer os.Remove(file)
+	if err := notifications.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	restoredScheduler := NewScheduler()
+	if _, err := NewNotifications(restoredScheduler).Restore(file); err != nil {
+		t.Fatal(err)
+	}
+	if restoredScheduler.Len() != 1 {
+		t.Errorf("Wanted the restored thread scheduled, got %d", restoredScheduler.Len())
+	}
+	restoredScheduler.Cancel("user1", "thread1")
+
+	notifications.Remove(notification, "user1", "thread1")
+	if s.Len() != 0 {
+		t.Errorf("Wanted nothing scheduled, got %d", s.Len())
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// defaultPresenceRecent is how recent activity has to be for a user to count
+// as present when none is configured
+const defaultPresenceRecent = 5 * time.Minute
+
+// Presence configures smart delivery, where a due ping waits until the target
+// shows activity. It is disabled while MaxWait is zero.
+type Presence struct {
+	MaxWait time.Duration `yaml:"max_wait"` // how long a due ping waits at most
+	Recent  time.Duration `yaml:"recent"`   // how recent activity counts as present
+}
+
+// presence is the smart delivery configuration
+var presence Presence
+
+// lastActivity holds the time each user was last seen active by ID
+var lastActivity = make(map[string]time.Time)
+
+// present returns true if the user with userID was active recently at now
+func present(userID string, now time.Time) bool {
+	recent := presence.Recent
+	if recent == 0 {
+		recent = defaultPresenceRecent
+	}
+	last, ok := lastActivity[userID]
+	return ok && now.Sub(last) < recent
+}
+
+// waitForPresence returns true if the due notification stored for the user
+// with userID under threadID should wait for them to show activity, and makes
+// it wait up to the configured max
+func waitForPresence(userID, threadID string, notif Notification, now time.Time) bool {
+	if presence.MaxWait == 0 || !notif.WaitingSince.IsZero() || present(userID, now) {
+		return false
+	}
+	log.Printf("User %v is not around, waiting up to %v to deliver notification %v", userID, presence.MaxWait, threadID)
+	notif.WaitingSince = now
+	notif.Timestamp = now.Add(presence.MaxWait)
+	notifications.Add(notif, userID, threadID)
+	return true
+}
+
+// seen records activity of the user with userID at t and makes the
+// notifications that were waiting for them before t due, as of when they
+// started waiting. It returns true if any were released.
+func seen(userID string, t time.Time) bool {
+	if t.After(lastActivity[userID]) {
+		lastActivity[userID] = t
+	}
+	waiting := false
+	for threadID, notifs := range notifications.ByUser[userID] {
+		for _, notif := range notifs {
+			if notif.WaitingSince.IsZero() || !notif.DeliveredAt.IsZero() || !t.After(notif.WaitingSince) {
+				continue
+			}
+			log.Printf("User %v is around, delivering notification %v", userID, threadID)
+			notif.Timestamp = notif.WaitingSince
+			notifications.Add(notif, userID, threadID)
+			waiting = true
+		}
+	}
+	return waiting
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitForPresence(t *testing.T) {
+	presence = Presence{MaxWait: 2 * time.Hour}
+	lastActivity = make(map[string]time.Time)
+	notifications = NewNotifications(nil)
+	now := time.Date(2026, 10, 14, 7, 0, 0, 0, time.UTC)
+
+	notif := NewNotification(now.Add(-time.Minute), "alice", "t1", "f", 1)
+	notifications.Add(notif, "2", "t1")
+	lastActivity["2"] = now.Add(-time.Minute)
+	if waitForPresence("2", "t1", notif, now) {
+		t.Error("Wanted no wait for a present user")
+	}
+
+	lastActivity["2"] = now.Add(-8 * time.Hour)
+	if !waitForPresence("2", "t1", notif, now) {
+		t.Fatal("Wanted a wait for an absent user")
+	}
+	notif = notifications.ByUser["2"]["t1"][0]
+	if !notif.Timestamp.Equal(now.Add(presence.MaxWait)) || !notif.WaitingSince.Equal(now) {
+		t.Errorf("Wanted the notification to wait until %v, got %+v", now.Add(presence.MaxWait), notif)
+	}
+	if waitForPresence("2", "t1", notif, now.Add(presence.MaxWait)) {
+		t.Error("Wanted no second wait once the max is reached")
+	}
+
+	if seen("2", now.Add(-3*time.Hour)) {
+		t.Error("Wanted activity from before the wait to keep the notification waiting")
+	}
+	later := now.Add(30 * time.Minute)
+	if !seen("2", later) {
+		t.Error("Wanted activity to release the waiting notification")
+	}
+	if notif := notifications.ByUser["2"]["t1"][0]; !notif.Timestamp.Equal(now) {
+		t.Errorf("Wanted the notification due at %v, got %v", now, notif.Timestamp)
+	}
+	if !present("2", later) {
+		t.Error("Wanted the user present after activity")
+	}
+
+	presence = Presence{}
+	if waitForPresence("3", "t1", NewNotification(now, "alice", "t1", "f", 1), now) {
+		t.Error("Wanted no wait with smart delivery disabled")
+	}
+}
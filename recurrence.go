@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Recurrence is the weekly schedule of a recurring notification. The zero
+// value means the notification is delivered only once.
+type Recurrence struct {
+	Weekdays [7]bool // by time.Weekday
+	Hour     int
+	Minute   int
+}
+
+// IsZero returns true if the recurrence has no days, i.e. does not recur
+func (r Recurrence) IsZero() bool {
+	return r.Weekdays == [7]bool{}
+}
+
+// Next returns the first time of the recurrence after t in location
+func (r Recurrence) Next(t time.Time, location *time.Location) time.Time {
+	t = t.In(location)
+	next := time.Date(t.Year(), t.Month(), t.Day(), r.Hour, r.Minute, 0, 0, location)
+	for i := 0; i < 8 && (!r.Weekdays[next.Weekday()] || !next.After(t)); i++ {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// String returns a description like "every monday and thursday at 10:00"
+func (r Recurrence) String() string {
+	var days []string
+	count := 0
+	for day := time.Monday; day <= time.Saturday+1; day++ {
+		weekday := day % 7
+		if r.Weekdays[weekday] {
+			days = append(days, strings.ToLower(weekday.String()))
+			count++
+		}
+	}
+	description := "every "
+	switch {
+	case count == 7:
+		description += "day"
+	case len(days) > 1:
+		description += strings.Join(days[:len(days)-1], ", ") + " and " + days[len(days)-1]
+	default:
+		description += strings.Join(days, "")
+	}
+	return description + " at " + time.Date(0, 1, 1, r.Hour, r.Minute, 0, 0, time.UTC).Format("15:04")
+}
+
+// ParseRecurrence parses a recurrence like "every monday 10:00", "every day
+// at 9", "every mon and thu at 14:30" or "every workday 9:30" at the start of
+// text. Workdays gives the days of "every workday". It returns the recurrence,
+// the number of bytes of text it spans and whether one was found. The time
+// defaults to 09:00.
+func ParseRecurrence(text string, workdays [7]bool) (Recurrence, int, bool) {
+	words, ends := splitWords(text)
+	if len(words) < 2 || words[0] != "every" {
+		return Recurrence{}, 0, false
+	}
+	r := Recurrence{Hour: 9}
+	used := 1
+	for ; used < len(words); used++ {
+		word := words[used]
+		if word == "and" && used > 1 {
+			continue
+		}
+		if word == "day" {
+			r.Weekdays = [7]bool{true, true, true, true, true, true, true}
+		} else if word == "weekday" || word == "workday" {
+			for day, works := range workdays {
+				r.Weekdays[day] = r.Weekdays[day] || works
+			}
+		} else if weekday, ok := weekdays[strings.TrimSuffix(word, "s")]; ok {
+			r.Weekdays[weekday] = true
+		} else {
+			break
+		}
+	}
+	if r.IsZero() {
+		return Recurrence{}, 0, false
+	}
+	if words[used-1] == "and" {
+		used--
+	}
+	clock := used
+	if clock < len(words) && words[clock] == "at" {
+		clock++
+	}
+	if clock < len(words) {
+		if hour, min, err := parseClock(words[clock]); err == nil {
+			r.Hour, r.Minute = hour, min
+			used = clock + 1
+		}
+	}
+	return r, ends[used-1], true
+}
+
+// splitWords splits text into lower cased words of letters, digits, colons
+// and dots and returns them with the byte offsets at which they end
+func splitWords(text string) ([]string, []int) {
+	var words []string
+	var ends []int
+	start := -1
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == ':' || r == '.'
+	}
+	add := func(end int) {
+		word := strings.TrimRight(text[start:end], ".:")
+		if word != "" {
+			words = append(words, strings.ToLower(word))
+			ends = append(ends, start+len(word))
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if isWordRune(r) && start < 0 {
+			start = i
+		} else if !isWordRune(r) && start >= 0 {
+			add(i)
+		}
+	}
+	if start >= 0 {
+		add(len(text))
+	}
+	return words, ends
+}
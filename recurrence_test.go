@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	monToFri := [7]bool{false, true, true, true, true, true, false}
+	tests := []struct {
+		text       string
+		workdays   [7]bool
+		want       string
+		consumed   string
+		recognized bool
+	}{
+		{" every monday 10:00 standup", monToFri, "every monday at 10:00", " every monday 10:00", true},
+		{"every day at 9", monToFri, "every day at 09:00", "every day at 9", true},
+		{"every Mon and Thu at 14:30.", monToFri, "every monday and thursday at 14:30", "every Mon and Thu at 14:30", true},
+		{"every mon, wed, fri", monToFri, "every monday, wednesday and friday at 09:00", "every mon, wed, fri", true},
+		{"every fridays and then some", monToFri, "every friday at 09:00", "every fridays", true},
+		{"every workday 9:30", [7]bool{true, true, true, true, true, false, false}, "every monday, tuesday, wednesday, thursday and sunday at 09:30", "every workday 9:30", true},
+		{"every time", monToFri, "", "", false},
+		{"review every monday", monToFri, "", "", false},
+		{"every", monToFri, "", "", false},
+	}
+	for _, test := range tests {
+		r, n, ok := ParseRecurrence(test.text, test.workdays)
+		if ok != test.recognized {
+			t.Errorf("ParseRecurrence(%q): wanted ok %v, got %v", test.text, test.recognized, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if r.String() != test.want {
+			t.Errorf("ParseRecurrence(%q): wanted %s, got %s", test.text, test.want, r)
+		}
+		if test.text[:n] != test.consumed {
+			t.Errorf("ParseRecurrence(%q): wanted to consume %q, consumed %q", test.text, test.consumed, test.text[:n])
+		}
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Helsinki")
+	r, _, _ := ParseRecurrence("every monday and thursday at 10:00", [7]bool{})
+
+	tests := []struct {
+		t    time.Time
+		want time.Time
+	}{
+		{time.Date(2026, 10, 12, 9, 0, 0, 0, location), time.Date(2026, 10, 12, 10, 0, 0, 0, location)},
+		{time.Date(2026, 10, 12, 10, 0, 0, 0, location), time.Date(2026, 10, 15, 10, 0, 0, 0, location)},
+		{time.Date(2026, 10, 15, 11, 0, 0, 0, location), time.Date(2026, 10, 19, 10, 0, 0, 0, location)},
+		// Over the end of summer time
+		{time.Date(2026, 10, 22, 11, 0, 0, 0, location), time.Date(2026, 10, 26, 10, 0, 0, 0, location)},
+	}
+	for _, test := range tests {
+		if got := r.Next(test.t, location); !got.Equal(test.want) {
+			t.Errorf("Next(%v): wanted %v, got %v", test.t, test.want, got)
+		}
+	}
+}
+
+func TestRecurrenceStoreAndRestore(t *testing.T) {
+	notifications := NewNotifications(nil)
+	r, _, _ := ParseRecurrence("every friday 15:00", [7]bool{})
+	notification := NewNotification(time.Now().Round(0), "pinger", "threadID", "flowID", 42)
+	notification.Recurrence = r
+	notifications.Add(notification, "user1", "thread1")
+
+	file := "/tmp/test-flowdock-recurring-notifications.gob"
+	defer os.Remove(file)
+	if err := notifications.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewNotifications(nil)
+	if _, err := restored.Restore(file); err != nil {
+		t.Fatal(err)
+	}
+	if restored.ByUser["user1"]["thread1"][0].Recurrence != r {
+		t.Errorf("Wanted recurrence %s, got %s", r, restored.ByUser["user1"]["thread1"][0].Recurrence)
+	}
+}
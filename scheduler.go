@@ -0,0 +1,166 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// scheduled is a notification waiting in the scheduler
+type scheduled struct {
+	At     time.Time
+	UserID string
+	Key    string
+	index  int
+}
+
+// scheduleQueue is a min-heap of scheduled notifications by time
+type scheduleQueue []*scheduled
+
+func (q scheduleQueue) Len() int           { return len(q) }
+func (q scheduleQueue) Less(i, j int) bool { return q[i].At.Before(q[j].At) }
+func (q scheduleQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *scheduleQueue) Push(x interface{}) {
+	s := x.(*scheduled)
+	s.index = len(*q)
+	*q = append(*q, s)
+}
+
+func (q *scheduleQueue) Pop() interface{} {
+	old := *q
+	s := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return s
+}
+
+// Scheduler keeps track of when notifications are due and wakes up exactly
+// at the next due time. Adding, cancelling and rescheduling take O(log n).
+type Scheduler struct {
+	queue   scheduleQueue
+	entries map[string]map[string]*scheduled
+	wake    chan struct{}
+	timer   Timer
+	armed   time.Time
+}
+
+// scheduler schedules the deliveries of notifications
+var scheduler = NewScheduler()
+
+// NewScheduler returns an empty scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{entries: make(map[string]map[string]*scheduled), wake: make(chan struct{}, 1)}
+}
+
+// Wake returns a channel that receives when the next notification is due
+func (s *Scheduler) Wake() <-chan struct{} {
+	return s.wake
+}
+
+// Len returns the number of scheduled notifications
+func (s *Scheduler) Len() int {
+	return len(s.queue)
+}
+
+// Schedule schedules the notification for the user with userID under key at
+// the given time, rescheduling it if it already is
+func (s *Scheduler) Schedule(userID, key string, at time.Time) {
+	if e, ok := s.entries[userID][key]; ok {
+		e.At = at
+		heap.Fix(&s.queue, e.index)
+	} else {
+		e = &scheduled{At: at, UserID: userID, Key: key}
+		if _, exists := s.entries[userID]; !exists {
+			s.entries[userID] = make(map[string]*scheduled)
+		}
+		s.entries[userID][key] = e
+		heap.Push(&s.queue, e)
+	}
+	s.rearm()
+}
+
+// Cancel removes the notification for the user with userID under key
+func (s *Scheduler) Cancel(userID, key string) {
+	e, ok := s.entries[userID][key]
+	if !ok {
+		return
+	}
+	heap.Remove(&s.queue, e.index)
+	delete(s.entries[userID], key)
+	if len(s.entries[userID]) == 0 {
+		delete(s.entries, userID)
+	}
+	s.rearm()
+}
+
+// Next returns the time the next notification is due, false if none are
+func (s *Scheduler) Next() (time.Time, bool) {
+	if len(s.queue) == 0 {
+		return time.Time{}, false
+	}
+	return s.queue[0].At, true
+}
+
+// Due removes and returns the notifications that are due at now, earliest
+// first
+func (s *Scheduler) Due(now time.Time) []scheduled {
+	var due []scheduled
+	for len(s.queue) > 0 && !s.queue[0].At.After(now) {
+		e := heap.Pop(&s.queue).(*scheduled)
+		delete(s.entries[e.UserID], e.Key)
+		if len(s.entries[e.UserID]) == 0 {
+			delete(s.entries, e.UserID)
+		}
+		due = append(due, *e)
+	}
+	// The timer may fire a bit early, so always arm it again
+	s.armed = time.Time{}
+	s.rearm()
+	return due
+}
+
+// Load schedules all the given notifications, replacing what was scheduled
+func (s *Scheduler) Load(n *Notifications) {
+	s.queue = s.queue[:0]
+	s.entries = make(map[string]map[string]*scheduled)
+	for userID, notifs := range n.ByUser {
+		s.entries[userID] = make(map[string]*scheduled)
+		for key := range notifs {
+			next, ok := n.next(userID, key)
+			if !ok {
+				continue
+			}
+			e := &scheduled{At: next, UserID: userID, Key: key, index: len(s.queue)}
+			s.entries[userID][key] = e
+			s.queue = append(s.queue, e)
+		}
+	}
+	heap.Init(&s.queue)
+	s.rearm()
+}
+
+// rearm sets the timer to wake up at the next due time, if it changed
+func (s *Scheduler) rearm() {
+	next, ok := s.Next()
+	if next.Equal(s.armed) && (ok || s.timer == nil) {
+		return
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.armed = next
+	if !ok {
+		return
+	}
+	s.timer = clock.AfterFunc(next.Sub(clock.Now()), func() {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	})
+}
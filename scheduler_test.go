@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler()
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	s.Schedule("1", "a", now.Add(3*time.Minute))
+	s.Schedule("1", "b", now.Add(1*time.Minute))
+	s.Schedule("2", "a", now.Add(2*time.Minute))
+	s.Schedule("2", "b", now.Add(4*time.Minute))
+
+	if next, ok := s.Next(); !ok || !next.Equal(now.Add(time.Minute)) {
+		t.Errorf("Wanted next at %v, got %v", now.Add(time.Minute), next)
+	}
+
+	s.Schedule("1", "b", now.Add(5*time.Minute))
+	s.Cancel("2", "a")
+	s.Cancel("2", "missing")
+	if s.Len() != 3 {
+		t.Errorf("Wanted 3 scheduled, got %d", s.Len())
+	}
+
+	due := s.Due(now.Add(4 * time.Minute))
+	if len(due) != 2 || due[0].UserID != "1" || due[0].Key != "a" || due[1].UserID != "2" || due[1].Key != "b" {
+		t.Errorf("Wanted 1/a and 2/b due in order, got %+v", due)
+	}
+	if due := s.Due(now.Add(4 * time.Minute)); len(due) != 0 {
+		t.Errorf("Wanted nothing due twice, got %+v", due)
+	}
+	if next, _ := s.Next(); !next.Equal(now.Add(5 * time.Minute)) {
+		t.Errorf("Wanted the rescheduled 1/b next, got %v", next)
+	}
+
+	s.Load(&Notifications{ByUser: map[string]map[string][]Notification{"3": {"c": {NewNotification(now, "alice", "c", "f", 1)}}}})
+	if s.Len() != 1 {
+		t.Errorf("Wanted only the loaded notification scheduled, got %d", s.Len())
+	}
+	s.Cancel("3", "c")
+	if _, ok := s.Next(); ok {
+		t.Error("Wanted an empty scheduler")
+	}
+}
+
+func TestSchedulerWakes(t *testing.T) {
+	s := NewScheduler()
+	s.Schedule("1", "a", time.Now().Add(10*time.Millisecond))
+	select {
+	case <-s.Wake():
+	case <-time.After(time.Second):
+		t.Fatal("Wanted the scheduler to wake when the notification is due")
+	}
+	if due := s.Due(time.Now()); len(due) != 1 {
+		t.Errorf("Wanted 1 due, got %d", len(due))
+	}
+}
+
+// BenchmarkScheduler reschedules and cancels notifications among 100k
+// pending ones
+func BenchmarkScheduler(b *testing.B) {
+	s := NewScheduler()
+	now := time.Now()
+	const pending = 100000
+	for i := 0; i < pending; i++ {
+		s.Schedule(fmt.Sprintf("user%d", i%500), fmt.Sprintf("thread%d", i), now.Add(time.Duration(i)*time.Second+time.Hour))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		user, thread := fmt.Sprintf("user%d", i%500), fmt.Sprintf("thread%d", i%pending)
+		s.Schedule(user, thread, now.Add(time.Duration(pending-i%pending)*time.Second+time.Hour))
+		s.Cancel(user, thread)
+		s.Schedule(user, thread, now.Add(time.Duration(i%pending)*time.Second+time.Hour))
+		s.Due(now)
+	}
+}
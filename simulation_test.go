@@ -0,0 +1,767 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gnyman/flowdock"
+)
+
+// fakeClock is a Clock that only moves when told to
+type fakeClock struct {
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a function scheduled on a fakeClock
+type fakeTimer struct {
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	wasActive := !t.stopped
+	t.stopped = true
+	return wasActive
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &fakeTimer{at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// fire moves the clock to the earliest timer due by until and runs it. It
+// returns false if no timer is due.
+func (c *fakeClock) fire(until time.Time) bool {
+	var next *fakeTimer
+	active := c.timers[:0]
+	for _, t := range c.timers {
+		if t.stopped {
+			continue
+		}
+		active = append(active, t)
+		if !t.at.After(until) && (next == nil || t.at.Before(next.at)) {
+			next = t
+		}
+	}
+	c.timers = active
+	if next == nil {
+		return false
+	}
+	if next.at.After(c.now) {
+		c.now = next.at
+	}
+	next.stopped = true
+	next.f()
+	return true
+}
+
+// sent is a call made to the fake Flowdock API
+type sent struct {
+	At      time.Time
+	Call    string
+	To      string // flow and thread or message, or user
+	Message string
+	Tags    []string
+}
+
+func (s sent) String() string {
+	return fmt.Sprintf("%s %s %s %q %v", s.At.Format("Mon 15:04"), s.Call, s.To, s.Message, s.Tags)
+}
+
+// fakeAPI records the calls made to the Flowdock API
+type fakeAPI struct {
+	clock  Clock
+	nextID int64
+	calls  []sent
+}
+
+func (a *fakeAPI) record(call, to, message string, tags []string) ([]byte, error) {
+	a.nextID++
+	a.calls = append(a.calls, sent{a.clock.Now(), call, to, message, tags})
+	return []byte(fmt.Sprintf(`{"id":%d}`, 1000+a.nextID)), nil
+}
+
+func (a *fakeAPI) SendMessage(flowID, threadID, message string) ([]byte, error) {
+	return a.record("message", flowID+"/"+threadID, message, nil)
+}
+
+func (a *fakeAPI) SendComment(flowID, messageID, message string) ([]byte, error) {
+	return a.record("comment", flowID+"/"+messageID, message, nil)
+}
+
+func (a *fakeAPI) EditMessage(org, flow, messageID, message string, tags []string) ([]byte, error) {
+	return a.record("edit", org+":"+flow+"/"+messageID, message, tags)
+}
+
+func (a *fakeAPI) SendPrivateMessage(userID, message string) ([]byte, error) {
+	return a.record("private", userID, message, nil)
+}
+
+// simulation replays scripted chat events against a fake clock and API
+type simulation struct {
+	t      *testing.T
+	clock  *fakeClock
+	api    *fakeAPI
+	nextID int64
+}
+
+// newSimulation sets up the bot with default settings, the users alice (1),
+// bob (2) and carol (3) and the flow "flow" of the organization "org", starting at the
+// given time
+func newSimulation(t *testing.T, start time.Time) (*simulation, func()) {
+	dir, err := ioutil.TempDir("", "simulation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := &fakeClock{now: start}
+	fa := &fakeAPI{clock: fc}
+	oldClock, oldAPI := clock, api
+	clock, api = fc, fa
+
+	notificationStorage = filepath.Join(dir, "notifications")
+	settingsStorage = filepath.Join(dir, "settings")
+	prefix, slowPrefix = "!", "!"
+	notifRegex = pingRegex(prefix)
+	tiers = defaultTiers
+	defaultLocation = start.Location()
+	defaultCalendar, _ = NewCalendar(WorkingHours{}, nil)
+	holidayCalendars = make(map[string]Holidays)
+	defaultHolidays = ""
+	userCalendars = make(map[string]*Calendar)
+	userConfigs = make(map[string]userConfig)
+	userSettings = NewUserSettings()
+	admins = make(map[string]bool)
+	statuses = make(map[string]string)
+	awayStatuses = nil
+	presence = Presence{}
+	clearing = Clearing{Default: clearThread}
+	botUserID = ""
+	lastActivity = make(map[string]time.Time)
+	scheduler = NewScheduler()
+	notifications = NewNotifications(scheduler)
+	deliveries = NewDeliveries()
+	recentMessages = NewRecentMessages()
+	users = NewUsers()
+	users.Add("alice", "1")
+	users.Add("bob", "2")
+	users.Add("carol", "3")
+	flows = map[string]flowdock.Flow{"flow": {ID: "flow", APIName: "flow", Organization: flowdock.Organization{APIName: "org"}}}
+
+	return &simulation{t: t, clock: fc, api: fa}, func() {
+		clock, api = oldClock, oldAPI
+		os.RemoveAll(dir)
+	}
+}
+
+// say posts a message by the user with userID to the thread in the flow
+func (s *simulation) say(userID, thread, content string) int64 {
+	s.nextID++
+	event := flowdock.MessageEvent{
+		ID:        s.nextID,
+		Flow:      "flow",
+		Content:   content,
+		Timestamp: s.clock.Now().UnixNano() / int64(time.Millisecond),
+		UserID:    userID,
+		ThreadID:  thread,
+	}
+	handleEvent(event)
+	return event.ID
+}
+
+// comment posts a comment by the user with userID on the message with parent
+// in the flow
+func (s *simulation) comment(userID, parent, content string) int64 {
+	s.nextID++
+	event := flowdock.CommentEvent{
+		Tags:      []string{"influx:" + parent},
+		ID:        s.nextID,
+		Flow:      "flow",
+		Timestamp: s.clock.Now().UnixNano() / int64(time.Millisecond),
+		UserID:    userID,
+	}
+	event.Content.Text = content
+	handleEvent(event)
+	return event.ID
+}
+
+// advance moves the clock forward by d, delivering notifications as the
+// scheduler wakes up
+func (s *simulation) advance(d time.Duration) {
+	until := s.clock.Now().Add(d)
+	for s.clock.fire(until) {
+		select {
+		case <-scheduler.Wake():
+			deliverDueNotifications(s.clock.Now())
+		default:
+		}
+	}
+	s.clock.now = until
+}
+
+// expect asserts the calls made to the API since the last expect
+func (s *simulation) expect(want ...sent) {
+	got := s.api.calls
+	s.api.calls = nil
+	for i := range got {
+		sort.Strings(got[i].Tags)
+	}
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		_, file, line, _ := runtime.Caller(1)
+		s.t.Errorf("%s:%d: wanted calls\n%v\ngot\n%v", filepath.Base(file), line, want, got)
+	}
+}
+
+func TestSimulationDelivery(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	// Wednesday
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	id := s.say("1", "t1", "!!bob can you review this?")
+	s.expect(sent{start, "edit", "org:flow/1", "", []string{"notify-short-bob"}})
+
+	s.advance(59 * time.Minute)
+	s.expect()
+
+	s.advance(2 * time.Minute)
+	s.expect(sent{start.Add(time.Hour), "message", "flow/t1", fmt.Sprintf("@bob, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/%d) for Wed 11:00 EEST: \"can you review this?\"", id), nil})
+
+	s.advance(24 * time.Hour)
+	s.expect()
+}
+
+func TestSimulationClearedByActivity(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	s.say("1", "t1", "!!!bob ping")
+	s.expect(sent{start, "edit", "org:flow/1", "", []string{"notify-shorter-bob"}})
+
+	s.advance(10 * time.Minute)
+	s.say("2", "t1", "on it")
+	s.expect(sent{start.Add(10 * time.Minute), "edit", "org:flow/2", "", []string{"cleared-bob"}})
+
+	s.advance(24 * time.Hour)
+	s.expect()
+}
+
+func TestSimulationNextWorkday(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	// Friday
+	start := time.Date(2026, 10, 16, 16, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	s.say("1", "t1", "!bob have a look")
+	s.expect(sent{start, "edit", "org:flow/1", "", []string{"notify-long-bob"}})
+
+	s.advance(24 * time.Hour)
+	s.say("1", "t2", "!bob on monday afternoon")
+	s.expect(sent{start.Add(24 * time.Hour), "edit", "org:flow/2", "", []string{"notify-at-bob"}})
+
+	s.advance(3 * 24 * time.Hour)
+	monday := time.Date(2026, 10, 19, 9, 0, 0, 0, helsinki)
+	s.expect(
+		sent{monday, "message", "flow/t1", "@bob, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/1) for Mon 09:00 EEST: \"have a look\"", nil},
+		sent{monday.Add(4 * time.Hour), "message", "flow/t2", "@bob, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/2) for Mon 13:00 EEST", nil},
+	)
+}
+
+func TestSimulationSeveralPingers(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	s.say("1", "t1", "!!bob see above")
+	s.say("3", "t1", "!bob no hurry")
+	s.advance(38 * time.Minute)
+	s.say("3", "t1", "!!!bob me too")
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"notify-short-bob"}},
+		sent{start, "edit", "org:flow/2", "", []string{"notify-long-bob"}},
+		sent{start.Add(38 * time.Minute), "edit", "org:flow/3", "", []string{"notify-shorter-bob"}},
+	)
+
+	// Pings due within a few minutes go out together, later ones keep their tier
+	s.advance(30 * time.Minute)
+	s.expect(sent{start.Add(time.Hour), "message", "flow/t1", "@bob, slow pings from Alice from [here](https://www.flowdock.com/app/org/flow/messages/1) \"see above\" and Carol from [here](https://www.flowdock.com/app/org/flow/messages/3) \"me too\"", nil})
+
+	s.advance(24 * time.Hour)
+	thursday := time.Date(2026, 10, 15, 9, 0, 0, 0, helsinki)
+	s.expect(sent{thursday, "message", "flow/t1", "@bob, slow ping from Carol from [here](https://www.flowdock.com/app/org/flow/messages/2) for Thu 09:00 EEST: \"no hurry\"", nil})
+}
+
+func TestSimulationCancel(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	s.say("1", "t1", "!!bob wrong person")
+	s.say("1", "t2", "!!carol question")
+	s.say("3", "t1", "!!bob other question")
+	s.say("1", "t1", "!cancel bob")
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"notify-short-bob"}},
+		sent{start, "edit", "org:flow/2", "", []string{"notify-short-carol"}},
+		sent{start, "edit", "org:flow/3", "", []string{"notify-short-bob"}},
+		sent{start, "edit", "org:flow/1", "", []string{"cancelled-bob"}},
+		sent{start, "message", "flow/t1", "Cancelled 1 ping(s).", nil},
+	)
+
+	s.say("1", "t1", "!cancel all")
+	s.expect(
+		sent{start, "edit", "org:flow/2", "", []string{"cancelled-carol"}},
+		sent{start, "message", "flow/t1", "Cancelled 1 ping(s).", nil},
+	)
+	s.say("1", "t1", "!cancel all")
+	s.expect(sent{start, "message", "flow/t1", "You have no pending pings.", nil})
+
+	s.advance(24 * time.Hour)
+	s.expect(sent{start.Add(time.Hour), "message", "flow/t1", "@bob, slow ping from Carol from [here](https://www.flowdock.com/app/org/flow/messages/3) for Wed 11:00 EEST: \"other question\"", nil})
+}
+
+func TestSimulationComments(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	s.comment("1", "100", "!!bob what about this one?")
+	s.comment("1", "200", "!!!bob and this")
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"notify-short-bob"}},
+		sent{start, "edit", "org:flow/2", "", []string{"notify-shorter-bob"}},
+	)
+
+	s.advance(10 * time.Minute)
+	s.comment("2", "200", "done")
+	s.expect(sent{start.Add(10 * time.Minute), "edit", "org:flow/3", "", []string{"cleared-bob"}})
+
+	s.advance(24 * time.Hour)
+	s.expect(sent{start.Add(time.Hour), "comment", "flow/100", "@bob, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/1) for Wed 11:00 EEST: \"what about this one?\"", nil})
+}
+
+// tag changes the tags of the message with messageID as the user with userID
+func (s *simulation) tag(userID string, messageID int64, added, removed []string) {
+	s.nextID++
+	event := flowdock.TagChangeEvent{
+		ID:        s.nextID,
+		Flow:      "flow",
+		Timestamp: s.clock.Now().UnixNano() / int64(time.Millisecond),
+		UserID:    userID,
+	}
+	event.Content.Added = added
+	event.Content.Removed = removed
+	event.Content.MessageID = messageID
+	handleEvent(event)
+}
+
+func TestSimulationTags(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	first := s.say("1", "t1", "!!bob !!carol please check")
+	second := s.say("1", "t2", "!!bob another one")
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"notify-short-bob"}},
+		sent{start, "edit", "org:flow/1", "", []string{"notify-short-carol"}},
+		sent{start, "edit", "org:flow/2", "", []string{"notify-short-bob"}},
+	)
+
+	// Only the target can acknowledge, only the pinger can cancel
+	s.tag("3", second, []string{"#ack"}, nil)
+	s.tag("2", first, nil, []string{"notify-short-carol"})
+	s.expect()
+
+	s.tag("2", first, []string{"seen"}, nil)
+	s.tag("1", second, nil, []string{"notify-short-bob"})
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"cleared-bob"}},
+		sent{start, "edit", "org:flow/2", "", []string{"cancelled-bob"}},
+	)
+
+	s.advance(24 * time.Hour)
+	s.expect(sent{start.Add(time.Hour), "message", "flow/t1", "@carol, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/1) for Wed 11:00 EEST: \"please check\"", nil})
+}
+
+// edit changes the content of the message with messageID as the user with
+// userID
+func (s *simulation) edit(userID string, messageID int64, content string) {
+	s.nextID++
+	event := flowdock.MessageEditEvent{
+		ID:        s.nextID,
+		Flow:      "flow",
+		Timestamp: s.clock.Now().UnixNano() / int64(time.Millisecond),
+		UserID:    userID,
+	}
+	event.Content.UpdatedMessage = content
+	event.Content.MessageID = messageID
+	handleEvent(event)
+}
+
+func TestSimulationEdits(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	first := s.say("1", "t1", "!!bbo please check")
+	second := s.say("1", "t2", "!!carol and !!bob, the deploy")
+	s.expect(
+		sent{start, "edit", "org:flow/2", "", []string{"notify-short-carol"}},
+		sent{start, "edit", "org:flow/2", "", []string{"notify-short-bob"}},
+	)
+
+	s.advance(5 * time.Minute)
+	// Fixing the typo schedules the ping as if it was there from the start
+	s.edit("1", first, "!!bob please check")
+	s.expect(sent{start.Add(5 * time.Minute), "edit", "org:flow/1", "", []string{"notify-short-bob"}})
+	// Removing carol cancels her ping, bob's is rescheduled for the new tier
+	s.edit("1", second, "!!!bob, the deploy")
+	s.expect(
+		sent{start.Add(5 * time.Minute), "edit", "org:flow/2", "", []string{"cancelled-carol"}},
+		sent{start.Add(5 * time.Minute), "edit", "org:flow/2", "", []string{"notify-shorter-bob"}},
+	)
+	// Edits that do not change the pings leave them alone
+	s.edit("1", first, "!!bob  please check")
+	s.edit("3", 999, "!!bob unknown")
+	s.expect()
+
+	s.advance(24 * time.Hour)
+	s.expect(
+		sent{start.Add(25 * time.Minute), "message", "flow/t2", "@bob, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/2) for Wed 10:25 EEST: \"the deploy\"", nil},
+		sent{start.Add(time.Hour), "message", "flow/t1", "@bob, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/1) for Wed 11:00 EEST: \"please check\"", nil},
+	)
+}
+
+func TestSimulationEditsAfterDelivery(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	delivered := s.say("1", "t1", "!!bob please chek")
+	s.advance(2 * time.Hour)
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"notify-short-bob"}},
+		sent{start.Add(time.Hour), "message", "flow/t1", "@bob, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/1) for Wed 11:00 EEST: \"please chek\"", nil},
+	)
+	// Fixing a typo does not deliver the ping again
+	s.edit("1", delivered, "!!bob please check")
+	s.expect()
+
+	now := start.Add(2 * time.Hour)
+	cleared := s.say("1", "t2", "!!bob the deploy")
+	reply := s.say("2", "t2", "on it")
+	s.expect(
+		sent{now, "edit", fmt.Sprintf("org:flow/%d", cleared), "", []string{"notify-short-bob"}},
+		sent{now, "edit", fmt.Sprintf("org:flow/%d", reply), "", []string{"cleared-bob"}},
+	)
+	// Nor does it bring back a cleared ping
+	s.edit("1", cleared, "!!bob the deploy, please")
+	s.expect()
+
+	s.advance(24 * time.Hour)
+	s.expect()
+}
+
+func TestSimulationDeletion(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	deleted := s.say("1", "t1", "!!bob and !!carol, please check")
+	s.say("1", "t1", "!!carol this one stays")
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"notify-short-bob"}},
+		sent{start, "edit", "org:flow/1", "", []string{"notify-short-carol"}},
+		sent{start, "edit", "org:flow/2", "", []string{"notify-short-carol"}},
+	)
+
+	s.edit("1", deleted, "")
+	s.expect()
+
+	s.advance(24 * time.Hour)
+	s.expect(sent{start.Add(time.Hour), "message", "flow/t1", "@carol, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/2) for Wed 11:00 EEST: \"this one stays\"", nil})
+}
+
+func TestSimulationClearing(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+	pinged := func(id int64) sent {
+		return sent{start, "edit", fmt.Sprintf("org:flow/%d", id), "", []string{"notify-short-bob"}}
+	}
+	cleared := func(id int64) sent {
+		return sent{start, "edit", fmt.Sprintf("org:flow/%d", id), "", []string{"cleared-bob"}}
+	}
+
+	// Any message in the flow clears
+	clearing.Flows = map[string]string{"org/flow": clearFlow}
+	id := s.say("1", "t1", "!!bob check the build")
+	s.expect(pinged(id))
+	s.expect(cleared(s.say("2", "t2", "on it")))
+
+	// Only an ack tag clears
+	clearing.Flows = map[string]string{"flow": clearNever}
+	id = s.say("1", "t1", "!!bob again")
+	s.say("2", "t1", "on it")
+	s.tag("2", id, []string{"looked"}, nil)
+	s.expect(pinged(id))
+	s.tag("2", id, []string{"ack"}, nil)
+	s.expect(cleared(id))
+
+	// Any tag by the target clears
+	clearing = Clearing{Default: clearTag}
+	id = s.say("1", "t1", "!!bob third")
+	s.say("2", "t1", "on it")
+	s.tag("2", id, []string{"looked"}, nil)
+	s.expect(pinged(id), cleared(id))
+
+	// A comment on the pinging message clears
+	clearing = Clearing{Default: clearComment}
+	id = s.say("1", "t1", "!!bob fourth")
+	s.say("2", "t1", "on it")
+	s.expect(pinged(id))
+	s.expect(cleared(s.comment("2", fmt.Sprint(id), "done")))
+
+	// The tier overrides the flow
+	clearing = Clearing{Default: clearNever}
+	tiers = append(Tiers(nil), defaultTiers...)
+	for i := range tiers {
+		if tiers[i].Repeat == 2 {
+			tiers[i].Clearing = clearFlow
+		}
+	}
+	id = s.say("1", "t1", "!!bob fifth")
+	s.expect(pinged(id))
+	s.expect(cleared(s.say("2", "t2", "on it")))
+
+	s.advance(24 * time.Hour)
+	s.expect()
+}
+
+func TestSimulationOwnMessages(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+	users.Add("notifybot", "4")
+	botUserID = "4"
+
+	id := s.say("4", "t1", "Remind yourself with !me, !!me or e.g. !me tomorrow")
+	s.comment("4", fmt.Sprint(id), "!!bob and !!me")
+	s.edit("4", id, "!!bob !me tomorrow")
+	s.advance(48 * time.Hour)
+	s.expect()
+}
+
+func TestSimulationTagsOfTiersAndRecurrences(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+	tiers = append(Tiers(nil), defaultTiers...)
+	for i := range tiers {
+		if tiers[i].Repeat == 2 {
+			tiers[i].Tag = "remind"
+		}
+	}
+
+	// Removing the tag of a configured tier cancels
+	pinged := s.say("1", "t1", "!!bob check")
+	s.tag("1", pinged, nil, []string{"remind-bob"})
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"remind-bob"}},
+		sent{start, "edit", "org:flow/1", "", []string{"cancelled-bob"}},
+	)
+
+	// Acknowledging a recurring ping does not end the recurrence
+	recurring := s.say("1", "t1", "!bob every monday 10:00 standup")
+	s.tag("2", recurring, []string{"ack"}, nil)
+	s.expect(sent{start, "edit", fmt.Sprintf("org:flow/%d", recurring), "", []string{"notify-every-bob"}})
+	if len(notifications.ByUser["2"]["flow/t1"]) != 1 {
+		t.Errorf("Expected the recurring ping to stay, got %+v", notifications.ByUser["2"])
+	}
+}
+
+// active reports activity of the user with userID at t
+func (s *simulation) active(userID string, t time.Time) {
+	s.nextID++
+	event := flowdock.UserActivityEvent{
+		ID:        s.nextID,
+		Flow:      "flow",
+		Timestamp: s.clock.Now().UnixNano() / int64(time.Millisecond),
+		UserID:    userID,
+	}
+	event.Content.LastActivityTimestamp = t.UnixNano() / int64(time.Millisecond)
+	handleEvent(event)
+}
+
+func TestSimulationPresence(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+	presence = Presence{MaxWait: 2 * time.Hour}
+
+	s.say("1", "t1", "!!bob please check")
+	s.advance(90 * time.Minute)
+	s.expect(sent{start, "edit", "org:flow/1", "", []string{"notify-short-bob"}})
+
+	// Activity from before the ping was due does not release it
+	s.active("2", start.Add(-3*time.Hour))
+	s.expect()
+
+	s.active("2", start.Add(80*time.Minute))
+	s.expect(sent{start.Add(90 * time.Minute), "message", "flow/t1", "@bob, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/1) for Wed 11:00 EEST: \"please check\"", nil})
+
+	s.advance(24 * time.Hour)
+	s.expect()
+}
+
+func TestSimulationCommandsAreNotActivity(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	s.say("1", "t1", "!!bob please check")
+	s.say("2", "t1", "!list thread")
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"notify-short-bob"}},
+		sent{start, "message", "flow/t1", "Pings pending in this thread:\n\n| Target | Pinger | Due | Message |\n| --- | --- | --- | --- |\n| bob | alice | Wed 14 Oct 11:00 EEST | [here](https://www.flowdock.com/app/org/flow/messages/1) |\n", nil},
+	)
+
+	// A word starting like a command is a plain message
+	listen := s.say("2", "t1", "!listen to this")
+	s.expect(sent{start, "edit", fmt.Sprintf("org:flow/%d", listen), "", []string{"cleared-bob"}})
+
+	s.advance(24 * time.Hour)
+	s.expect()
+}
+
+func TestSimulationEscalation(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+	tiers = append(Tiers(nil), defaultTiers...)
+	for i := range tiers {
+		if tiers[i].Repeat == 2 {
+			tiers[i].Escalation = []EscalationStep{
+				{After: 30 * time.Minute, Action: escalateRepeat},
+				{After: 2 * time.Hour, Action: escalatePrivate},
+				{After: 4 * time.Hour, Action: escalateNotifyPinger},
+			}
+		}
+	}
+	link := "[here](https://www.flowdock.com/app/org/flow/messages/1)"
+
+	s.say("1", "t1", "!!bob please check")
+	s.advance(24 * time.Hour)
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"notify-short-bob"}},
+		sent{start.Add(time.Hour), "message", "flow/t1", "@bob, slow ping from Alice from " + link + " for Wed 11:00 EEST: \"please check\"", nil},
+		sent{start.Add(90 * time.Minute), "message", "flow/t1", "@bob, the slow ping from Alice from " + link + " is still waiting for you", nil},
+		sent{start.Add(3 * time.Hour), "private", "2", "Alice is waiting for your answer " + link, nil},
+		sent{start.Add(5 * time.Hour), "private", "1", "bob has not answered your ping " + link, nil},
+	)
+
+	// Activity of the target in the thread stops the escalation
+	now := start.Add(24 * time.Hour)
+	pinged := s.say("1", "t2", "!!bob the deploy")
+	s.advance(80 * time.Minute)
+	answer := s.say("2", "t2", "done")
+	s.advance(24 * time.Hour)
+	s.expect(
+		sent{now, "edit", fmt.Sprintf("org:flow/%d", pinged), "", []string{"notify-short-bob"}},
+		sent{now.Add(time.Hour), "message", "flow/t2", fmt.Sprintf("@bob, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/%d) for Thu 11:00 EEST: \"the deploy\"", pinged), nil},
+		sent{now.Add(80 * time.Minute), "edit", fmt.Sprintf("org:flow/%d", answer), "", []string{"cleared-bob"}},
+	)
+}
+
+func TestSimulationSnooze(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	s.say("1", "t1", "!!bob please check")
+	s.advance(70 * time.Minute)
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"notify-short-bob"}},
+		sent{start.Add(time.Hour), "message", "flow/t1", "@bob, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/1) for Wed 11:00 EEST: \"please check\"", nil},
+	)
+
+	now := start.Add(70 * time.Minute)
+	s.say("2", "t2", "!snooze")
+	s.expect(sent{now, "message", "flow/t2", "There is no ping to you in this thread to snooze.", nil})
+
+	// The delivery message is tagged and the ping delivered again later
+	s.say("2", "t1", "!snooze 30m")
+	s.expect(
+		sent{now, "edit", "org:flow/1002", "", []string{"snoozed-bob"}},
+		sent{now, "message", "flow/t1", "Snoozed until Wed 11:40 EEST.", nil},
+	)
+	s.advance(24 * time.Hour)
+	s.expect(sent{now.Add(30 * time.Minute), "message", "flow/t1", "@bob, slow ping from Alice from [here](https://www.flowdock.com/app/org/flow/messages/1) for Wed 11:40 EEST: \"please check\"", nil})
+}
+
+func TestSimulationRecurring(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, helsinki)
+	s, done := newSimulation(t, start)
+	defer done()
+
+	s.say("1", "t1", "!bob every thursday 10:00 standup")
+	s.advance(25 * time.Hour)
+	thursday := time.Date(2026, 10, 15, 10, 0, 0, 0, helsinki)
+	s.expect(
+		sent{start, "edit", "org:flow/1", "", []string{"notify-every-bob"}},
+		sent{thursday, "message", "flow/t1", "@bob, recurring ping (every thursday at 10:00) from Alice from [here](https://www.flowdock.com/app/org/flow/messages/1): \"standup\"", nil},
+	)
+
+	// After the delivery the ping is rescheduled for the next week
+	if notifs := notifications.ByUser["2"]["flow/t1"]; len(notifs) != 1 || !notifs[0].Timestamp.Equal(thursday.AddDate(0, 0, 7)) {
+		t.Errorf("Wanted the ping rescheduled to %v, got %+v", thursday.AddDate(0, 0, 7), notifs)
+	}
+	now := start.Add(25 * time.Hour)
+	s.say("1", "t2", "!recurring")
+	s.say("1", "t2", "!recurring cancel 1")
+	s.say("1", "t2", "!recurring cancel 1")
+	s.expect(
+		sent{now, "message", "flow/t2", "Recurring pings:\n- every thursday at 10:00 for bob from alice, next Thu 10:00 EEST, created [here](https://www.flowdock.com/app/org/flow/messages/1) (id 1)\nCancel one with !recurring cancel <id>.", nil},
+		sent{now, "message", "flow/t2", "Cancelled 1 recurring ping(s).", nil},
+		sent{now, "message", "flow/t2", "You have no recurring ping with id 1.", nil},
+	)
+
+	s.advance(8 * 24 * time.Hour)
+	s.expect()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jabbors/flowdock-notifybot/timeexpr"
+)
+
+// defaultSnooze is used when the snooze command is given without a time
+const defaultSnooze = 1 * time.Hour
+
+// snoozeTime returns when a notification snoozed by the user with nick at now
+// shall be delivered again. The args are a duration like 2h, a time
+// expression like tomorrow, or empty for the default snooze.
+func snoozeTime(nick, args string, now time.Time) (time.Time, error) {
+	args = strings.TrimSpace(args)
+	calendar := calendarFor(nick)
+	var t time.Time
+	if args == "" {
+		t = now.Add(defaultSnooze)
+	} else if d, err := parseDuration(args); err == nil {
+		t = now.Add(d)
+	} else if parsed, _, ok := timeexpr.Parse(args, now.In(calendar.Location)); ok {
+		t = parsed
+	} else {
+		return time.Time{}, fmt.Errorf("Unknown snooze time '%s', use a duration like 30m or 2h, or e.g. tomorrow", args)
+	}
+	t, _ = calendar.Defer(t)
+	return t, nil
+}
+
+// snoozeCommand handles the snooze command sent by the user with nick and ID
+// in the conversation stored under key and returns the reply to send back
+func snoozeCommand(nick, userID, key, args string) string {
+	delivery, found := deliveries[userID][key]
+	if !found {
+		return "There is no ping to you in this thread to snooze."
+	}
+	t, err := snoozeTime(nick, args, clock.Now())
+	if err != nil {
+		return err.Error()
+	}
+	for _, notification := range delivery.Notifications {
+		notification.Timestamp = t
+		notification.WaitingSince = time.Time{}
+		notifications.Add(notification, userID, delivery.Key)
+		log.Printf("%s snoozed the notification from %s until %v", nick, notification.Pinger, t)
+	}
+	notifications.Save(notificationStorage)
+	deliveries.Delete(userID, key)
+
+	flow := flows[delivery.Notifications[0].Flow]
+	snoozeTag := fmt.Sprintf("snoozed-%s", nick)
+	api.EditMessage(flow.Organization.APIName, flow.APIName, strconv.FormatInt(delivery.MessageID, 10), "", []string{snoozeTag})
+	return fmt.Sprintf("Snoozed until %s.", t.In(locationFor(nick)).Format("Mon 15:04 MST"))
+}
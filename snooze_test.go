@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnoozeTime(t *testing.T) {
+	defaultLocation, _ = time.LoadLocation("Europe/Helsinki")
+	userConfigs = make(map[string]userConfig)
+	userSettings = NewUserSettings()
+	defaultCalendar, _ = NewCalendar(WorkingHours{}, nil)
+	// Wednesday
+	now := time.Date(2026, 10, 14, 15, 0, 0, 0, defaultLocation)
+
+	tests := map[string]time.Time{
+		"":                   now.Add(defaultSnooze),
+		" 30m":               now.Add(30 * time.Minute),
+		"2h":                 now.Add(2 * time.Hour),
+		"1d":                 now.Add(24 * time.Hour),
+		"tomorrow":           time.Date(2026, 10, 15, 9, 0, 0, 0, defaultLocation),
+		"tomorrow afternoon": time.Date(2026, 10, 15, 13, 0, 0, 0, defaultLocation),
+	}
+	for args, want := range tests {
+		got, err := snoozeTime("bob", args, now)
+		if err != nil {
+			t.Errorf("snoozeTime(%q): unexpected error %v", args, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("snoozeTime(%q): wanted %v, got %v", args, want, got)
+		}
+	}
+
+	if _, err := snoozeTime("bob", "whenever", now); err == nil {
+		t.Error("Expected an error for an unknown snooze time")
+	}
+}
+
+func TestSnoozeTimeWithinWorkingHours(t *testing.T) {
+	defaultLocation, _ = time.LoadLocation("Europe/Helsinki")
+	userConfigs = make(map[string]userConfig)
+	userSettings = NewUserSettings()
+	defaultCalendar, _ = NewCalendar(WorkingHours{Start: "09:00", End: "17:00"}, nil)
+	defer func() { defaultCalendar, _ = NewCalendar(WorkingHours{}, nil) }()
+	// Friday afternoon
+	now := time.Date(2026, 10, 16, 16, 30, 0, 0, defaultLocation)
+
+	got, _ := snoozeTime("bob", "1h", now)
+	if want := time.Date(2026, 10, 19, 9, 0, 0, 0, defaultLocation); !got.Equal(want) {
+		t.Errorf("Wanted %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+)
+
+// statusRecheck is how often held notifications are looked at again in case
+// a status change was missed
+const statusRecheck = time.Hour
+
+// statuses holds the latest Flowdock status of each user by ID
+var statuses = make(map[string]string)
+
+// awayStatuses are the patterns of statuses that mean a user is away
+var awayStatuses []*regexp.Regexp
+
+// compileAwayStatuses compiles the configured away status patterns, which
+// match case insensitively
+func compileAwayStatuses(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid away status '%s': %v", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// awayByStatus returns the status of the user with userID and whether it
+// means they are away
+func awayByStatus(userID string) (string, bool) {
+	status := statuses[userID]
+	if status == "" {
+		return "", false
+	}
+	for _, re := range awayStatuses {
+		if re.MatchString(status) {
+			return status, true
+		}
+	}
+	return status, false
+}
+
+// holdForStatus holds back the notification stored for the user with userID
+// under threadID while their status says they are away
+func holdForStatus(userID, threadID string, notif Notification, now time.Time) {
+	if !notif.Held {
+		log.Printf("User %v has an away status, holding notification %v", userID, threadID)
+	}
+	notif.Held = true
+	notif.Timestamp = now.Add(statusRecheck)
+	notifications.Add(notif, userID, threadID)
+}
+
+// setStatus records a status change of the user with userID and re-evaluates
+// their pending notifications. Held notifications are released as soon as
+// the status no longer means away, within the working hours of the user.
+// It returns true if any were released.
+func setStatus(userID, status string, now time.Time) bool {
+	statuses[userID] = status
+	if _, away := awayByStatus(userID); away {
+		return false
+	}
+	released := false
+	calendar := calendarFor(users.Nick(userID))
+	for threadID, notifs := range notifications.ByUser[userID] {
+		for _, notif := range notifs {
+			if !notif.Held {
+				continue
+			}
+			notif.Held = false
+			notif.Timestamp, _ = calendar.Defer(now)
+			notifications.Add(notif, userID, threadID)
+			log.Printf("User %v is back, releasing notification %v at %v", userID, threadID, notif.Timestamp)
+			released = true
+		}
+	}
+	return released
+}
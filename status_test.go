@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAwayByStatus(t *testing.T) {
+	awayStatuses, _ = compileAwayStatuses([]string{"vacation", `\booo\b`, "sick"})
+	statuses = map[string]string{"1": "On vacation until Monday", "2": "OOO", "3": "Zooo keeper", "4": ""}
+	tests := map[string]bool{"1": true, "2": true, "3": false, "4": false, "5": false}
+	for userID, want := range tests {
+		if _, got := awayByStatus(userID); got != want {
+			t.Errorf("awayByStatus(%q): wanted %v, got %v", userID, want, got)
+		}
+	}
+	if _, err := compileAwayStatuses([]string{"("}); err == nil {
+		t.Error("Wanted an invalid pattern to fail")
+	}
+}
+
+func TestSetStatusReleasesHeld(t *testing.T) {
+	defaultLocation, _ = time.LoadLocation("Europe/Helsinki")
+	userConfigs = make(map[string]userConfig)
+	userSettings = NewUserSettings()
+	defaultCalendar, _ = NewCalendar(WorkingHours{}, nil)
+	users = Users{"bob": "2"}
+	awayStatuses, _ = compileAwayStatuses([]string{"vacation"})
+	statuses = make(map[string]string)
+	notifications = NewNotifications(nil)
+	now := time.Date(2026, 10, 14, 15, 0, 0, 0, defaultLocation)
+
+	notifications.Add(NewNotification(now.Add(-time.Minute), "alice", "t1", "f", 1), "2", "t1")
+	notifications.Add(NewNotification(now.Add(time.Hour), "alice", "t2", "f", 2), "2", "t2")
+	if setStatus("2", "vacation", now) {
+		t.Error("Wanted nothing released when going away")
+	}
+	holdForStatus("2", "t1", notifications.ByUser["2"]["t1"][0], now)
+	if notif := notifications.ByUser["2"]["t1"][0]; !notif.Held || !notif.Timestamp.Equal(now.Add(statusRecheck)) {
+		t.Errorf("Wanted t1 held, got %+v", notif)
+	}
+
+	later := now.Add(10 * time.Minute)
+	if !setStatus("2", "back", later) {
+		t.Error("Wanted held notifications released")
+	}
+	if notif := notifications.ByUser["2"]["t1"][0]; notif.Held || !notif.Timestamp.Equal(later) {
+		t.Errorf("Wanted t1 released at %v, got %+v", later, notif)
+	}
+	if notif := notifications.ByUser["2"]["t2"][0]; !notif.Timestamp.Equal(now.Add(time.Hour)) {
+		t.Errorf("Wanted t2 untouched, got %+v", notif)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gnyman/flowdock"
+)
+
+// defaultAckTags are the tags that acknowledge a ping when none are configured
+var defaultAckTags = []string{"ack", "seen"}
+
+// ackTags are the tags a target adds to a pinging message to acknowledge it
+var ackTags = normalizeTags(defaultAckTags)
+
+// normalizeTags returns the tags lower cased and without a leading #
+func normalizeTags(tags []string) map[string]bool {
+	normalized := make(map[string]bool)
+	for _, tag := range tags {
+		normalized[strings.ToLower(strings.TrimPrefix(tag, "#"))] = true
+	}
+	return normalized
+}
+
+// pingedNick returns the nick a notify tag of a pinging message is for: the
+// tag of a tier, notify-at or notify-every followed by -<nick>
+func pingedNick(tag string) (string, bool) {
+	prefixes := []string{"notify-at", "notify-every"}
+	for _, tier := range tiers {
+		prefixes = append(prefixes, tier.Tag)
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(tag, prefix+"-") {
+			return tag[len(prefix)+1:], true
+		}
+	}
+	return "", false
+}
+
+// removeFromMessage removes the notifications created from the message with
+// messageID in the flow that match and returns how many were removed. The
+// message is tagged with the given tag prefix and the nick of each target.
+func removeFromMessage(flowID string, messageID int64, tag string, match func(to string, notif Notification) bool) int {
+	removed := 0
+	for to, threads := range notifications.ByUser {
+		for key, notifs := range threads {
+			for _, notif := range notifs {
+				if notif.Flow != flowID || notif.MessageID != messageID || !match(to, notif) {
+					continue
+				}
+				notifications.Remove(notif, to, key)
+				removed++
+				if org, flow, ok := flowNames(flowID); ok {
+					api.EditMessage(org, flow, strconv.FormatInt(messageID, 10), "", []string{fmt.Sprintf("%s-%s", tag, users.Nick(to))})
+				}
+			}
+		}
+	}
+	return removed
+}
+
+// handleTagChange clears the one-shot notifications of a target that adds an
+// ack tag, or any tag if their clearing policy is tag, to the pinging message,
+// and cancels those of a pinger that removes the notify tag of a target
+func handleTagChange(event flowdock.TagChangeEvent) {
+	nick := users.Nick(event.UserID)
+	messageID := event.Content.MessageID
+	changed := 0
+	for _, tag := range event.Content.Added {
+		ack := ackTags[strings.ToLower(strings.TrimPrefix(tag, "#"))]
+		n := removeFromMessage(event.Flow, messageID, "cleared", func(to string, notif Notification) bool {
+			return to == event.UserID && (ack && notif.Recurrence.IsZero() || notif.ClearedByActivity() && clearingPolicy(notif) == clearTag)
+		})
+		if n > 0 {
+			log.Printf("%s acknowledged %d notification(s) from message %d with #%s", nick, n, messageID, tag)
+		}
+		changed += n
+	}
+	for _, tag := range event.Content.Removed {
+		target, ok := pingedNick(tag)
+		if !ok || !users.Exists(target) {
+			continue
+		}
+		n := removeFromMessage(event.Flow, messageID, "cancelled", func(to string, notif Notification) bool {
+			return to == users[target] && strings.EqualFold(notif.Pinger, nick)
+		})
+		if n > 0 {
+			log.Printf("%s cancelled %d notification(s) for %s from message %d by removing %s", nick, n, target, messageID, tag)
+		}
+		changed += n
+	}
+	if changed > 0 {
+		notifications.Save(notificationStorage)
+	}
+}
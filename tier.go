@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Tier maps the number of times the prefix of a ping is repeated to the
+// delivery time of the notification and the tag put on the pinging message
+type Tier struct {
+	Repeat        int              `yaml:"repeat"`
+	Delay         time.Duration    `yaml:"delay"`
+	NextWorkdayAt string           `yaml:"next_workday_at"`
+	Tag           string           `yaml:"tag"`
+	Escalation    []EscalationStep `yaml:"escalation"`
+	Clearing      string           `yaml:"clearing"` // overrides the clearing policy
+}
+
+// Tiers is the list of configured tiers
+type Tiers []Tier
+
+// defaultTiers is used when no tiers are configured
+var defaultTiers = Tiers{
+	{Repeat: 1, NextWorkdayAt: "09:00", Tag: "notify-long"},
+	{Repeat: 2, Delay: 1 * time.Hour, Tag: "notify-short"},
+	{Repeat: 3, Delay: 25 * time.Minute, Tag: "notify-shorter"},
+}
+
+// Validate returns an error if any of the tiers is invalid
+func (t Tiers) Validate() error {
+	if len(t) == 0 {
+		return fmt.Errorf("no tiers given")
+	}
+	repeats := make(map[int]bool)
+	for _, tier := range t {
+		if tier.Repeat < 1 {
+			return fmt.Errorf("tier repeat must be at least 1, got %d", tier.Repeat)
+		}
+		if repeats[tier.Repeat] {
+			return fmt.Errorf("tier repeat %d given more than once", tier.Repeat)
+		}
+		repeats[tier.Repeat] = true
+		if (tier.Delay > 0) == (tier.NextWorkdayAt != "") {
+			return fmt.Errorf("tier %d must have either a positive delay or next_workday_at", tier.Repeat)
+		}
+		if tier.NextWorkdayAt != "" {
+			if _, _, err := parseClock(tier.NextWorkdayAt); err != nil {
+				return fmt.Errorf("tier %d: %v", tier.Repeat, err)
+			}
+		}
+		if tier.Tag == "" || strings.ContainsAny(tier.Tag, " ,#") {
+			return fmt.Errorf("tier %d has an invalid tag '%s'", tier.Repeat, tier.Tag)
+		}
+		if err := validateEscalation(tier.Escalation); err != nil {
+			return fmt.Errorf("tier %d: %v", tier.Repeat, err)
+		}
+		if tier.Clearing != "" {
+			if err := validateClearing(tier.Clearing); err != nil {
+				return fmt.Errorf("tier %d: %v", tier.Repeat, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Find returns the tier for prefix repeated repeat times
+func (t Tiers) Find(repeat int) (Tier, bool) {
+	for _, tier := range t {
+		if tier.Repeat == repeat {
+			return tier, true
+		}
+	}
+	return Tier{}, false
+}
+
+// Time returns the delivery time of a notification created at now for a
+// target with the given calendar
+func (t Tier) Time(now time.Time, calendar *Calendar) time.Time {
+	if t.NextWorkdayAt != "" {
+		hour, min, _ := parseClock(t.NextWorkdayAt)
+		return calendar.NextWorkdayAt(now, hour, min)
+	}
+	return now.In(calendar.Location).Add(t.Delay)
+}
+
+// Describe returns a description of the delivery time of the tier
+func (t Tier) Describe() string {
+	if t.NextWorkdayAt != "" {
+		return fmt.Sprintf("the following workday at %s in their timezone", t.NextWorkdayAt)
+	}
+	return "after " + formatDuration(t.Delay)
+}
+
+// formatDuration formats d like time.Duration.String without trailing zero
+// units, e.g. 1h instead of 1h0m0s
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestTiersFromConfig(t *testing.T) {
+	content := `
+tiers:
+  - repeat: 1
+    next_workday_at: "10:30"
+    tag: notify-tomorrow
+  - repeat: 2
+    delay: 2h
+    tag: notify-later
+`
+	var conf config
+	err := yaml.Unmarshal([]byte(content), &conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conf.Tiers.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location, _ := time.LoadLocation("Europe/Helsinki")
+	calendar, _ := NewCalendar(WorkingHours{}, nil)
+	calendar.Location = location
+	// Friday
+	now := time.Date(2026, 10, 16, 15, 0, 0, 0, location)
+
+	tier, found := conf.Tiers.Find(1)
+	if !found {
+		t.Fatal("Tier 1 not found")
+	}
+	if want := time.Date(2026, 10, 19, 10, 30, 0, 0, location); !tier.Time(now, calendar).Equal(want) {
+		t.Errorf("Tier 1: wanted %v, got %v", want, tier.Time(now, calendar))
+	}
+	tier, _ = conf.Tiers.Find(2)
+	if want := now.Add(2 * time.Hour); !tier.Time(now, calendar).Equal(want) {
+		t.Errorf("Tier 2: wanted %v, got %v", want, tier.Time(now, calendar))
+	}
+	if _, found := conf.Tiers.Find(3); found {
+		t.Error("Tier 3 should not exist")
+	}
+}
+
+func TestTiersValidate(t *testing.T) {
+	if err := defaultTiers.Validate(); err != nil {
+		t.Errorf("Default tiers are invalid: %v", err)
+	}
+
+	invalid := map[string]Tiers{
+		"empty":          {},
+		"zero repeat":    {{Repeat: 0, Delay: time.Hour, Tag: "notify"}},
+		"duplicate":      {{Repeat: 1, Delay: time.Hour, Tag: "a"}, {Repeat: 1, Delay: time.Minute, Tag: "b"}},
+		"no time":        {{Repeat: 1, Tag: "notify"}},
+		"both times":     {{Repeat: 1, Delay: time.Hour, NextWorkdayAt: "09:00", Tag: "notify"}},
+		"invalid clock":  {{Repeat: 1, NextWorkdayAt: "9am", Tag: "notify"}},
+		"negative delay": {{Repeat: 1, Delay: -time.Hour, Tag: "notify"}},
+		"no tag":         {{Repeat: 1, Delay: time.Hour}},
+		"invalid tag":    {{Repeat: 1, Delay: time.Hour, Tag: "notify me"}},
+	}
+	for name, tiers := range invalid {
+		if err := tiers.Validate(); err == nil {
+			t.Errorf("Expected %s tiers to be invalid", name)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		time.Hour:                  "1h",
+		25 * time.Minute:           "25m",
+		90 * time.Minute:           "1h30m",
+		30 * time.Second:           "30s",
+		time.Hour + 10*time.Second: "1h0m10s",
+	}
+	for d, want := range tests {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%v): wanted %s, got %s", d, want, got)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Package timeexpr parses natural-language time expressions such as
+// "tomorrow afternoon", "next tuesday", "in 3 days" or "end of day". English
+// and Finnish expressions are understood.
+package timeexpr
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Default times of day used when an expression does not give one
+const (
+	defaultHour  = 9
+	endOfDayHour = 17
+)
+
+var weekdays = map[string]time.Weekday{
+	"monday": time.Monday, "maanantaina": time.Monday,
+	"tuesday": time.Tuesday, "tiistaina": time.Tuesday,
+	"wednesday": time.Wednesday, "keskiviikkona": time.Wednesday,
+	"thursday": time.Thursday, "torstaina": time.Thursday,
+	"friday": time.Friday, "perjantaina": time.Friday,
+	"saturday": time.Saturday, "lauantaina": time.Saturday,
+	"sunday": time.Sunday, "sunnuntaina": time.Sunday,
+}
+
+// finnishWeekdays are weekdays in the essive case, which alone mean the next
+// such day, e.g. "tiistaina"
+var finnishWeekdays = map[string]bool{
+	"maanantaina": true, "tiistaina": true, "keskiviikkona": true, "torstaina": true,
+	"perjantaina": true, "lauantaina": true, "sunnuntaina": true,
+}
+
+var partsOfDay = map[string]int{
+	"morning": 9, "aamulla": 9, "aamuna": 9,
+	"forenoon": 10, "aamupäivällä": 10,
+	"noon": 12, "lunch": 12, "keskipäivällä": 12, "lounaalla": 12,
+	"afternoon": 13, "iltapäivällä": 13, "iltapäivänä": 13,
+	"evening": 18, "illalla": 18, "iltana": 18,
+}
+
+type unit int
+
+const (
+	minute unit = iota
+	hour
+	day
+	week
+)
+
+var units = map[string]unit{
+	"minute": minute, "minutes": minute, "min": minute, "mins": minute, "minuutin": minute,
+	"hour": hour, "hours": hour, "tunnin": hour,
+	"day": day, "days": day, "päivän": day,
+	"week": week, "weeks": week, "viikon": week,
+}
+
+var numbers = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"yhden": 1, "kahden": 2, "kolmen": 3, "neljän": 4, "viiden": 5,
+}
+
+// token is a lower cased word of the parsed text and the byte offset at which
+// it ends
+type token struct {
+	word string
+	end  int
+}
+
+// Parse parses the time expression at the start of text relative to ref. The
+// result is in the location of ref. It returns the time, the number of bytes
+// of text the expression spans and whether text started with an expression
+// resolving to a time after ref.
+func Parse(text string, ref time.Time) (time.Time, int, bool) {
+	tokens := tokenize(text)
+	for _, parse := range []func([]token, time.Time) (time.Time, int, bool){parseRelative, parseEndOf, parseDay} {
+		t, used, ok := parse(tokens, ref)
+		if ok && t.After(ref) {
+			return t, tokens[used-1].end, true
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+// tokenize splits text into words of letters, digits, colons and dots. An
+// apostrophe within a word is part of it, so that "tonight's" is not "tonight".
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == ':' || r == '.' || start >= 0 && (r == '\'' || r == '’')
+	}
+	add := func(end int) {
+		word := strings.TrimRight(text[start:end], ".:'’")
+		if word != "" {
+			tokens = append(tokens, token{strings.ToLower(word), start + len(word)})
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if isWordRune(r) && start < 0 {
+			start = i
+		} else if !isWordRune(r) && start >= 0 {
+			add(i)
+		}
+	}
+	if start >= 0 {
+		add(len(text))
+	}
+	return tokens
+}
+
+// words returns whether tokens start with the given words
+func words(tokens []token, words ...string) bool {
+	if len(tokens) < len(words) {
+		return false
+	}
+	for i, word := range words {
+		if tokens[i].word != word {
+			return false
+		}
+	}
+	return true
+}
+
+// at returns the date of t at hour:min
+func at(t time.Time, hour, min int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), hour, min, 0, 0, t.Location())
+}
+
+// nextWeekday returns the first date after the date of t that is weekday
+func nextWeekday(t time.Time, weekday time.Weekday) time.Time {
+	days := (int(weekday)-int(t.Weekday())+6)%7 + 1
+	return t.AddDate(0, 0, days)
+}
+
+// parseRelative parses "in 3 days", "in an hour", "3 päivän päästä" and
+// "viikon kuluttua"
+func parseRelative(tokens []token, ref time.Time) (time.Time, int, bool) {
+	var n, used int
+	var u unit
+	var ok bool
+	switch {
+	case words(tokens, "in") && len(tokens) >= 3:
+		n, ok = parseNumber(tokens[1].word)
+		if !ok {
+			return time.Time{}, 0, false
+		}
+		u, ok = units[tokens[2].word]
+		used = 3
+	case len(tokens) >= 3 && isAfter(tokens[2].word):
+		n, ok = parseNumber(tokens[0].word)
+		if !ok {
+			return time.Time{}, 0, false
+		}
+		u, ok = units[tokens[1].word]
+		used = 3
+	case len(tokens) >= 2 && isAfter(tokens[1].word):
+		n = 1
+		u, ok = units[tokens[0].word]
+		used = 2
+	}
+	if !ok || n <= 0 {
+		return time.Time{}, 0, false
+	}
+	switch u {
+	case minute:
+		return ref.Add(time.Duration(n) * time.Minute), used, true
+	case hour:
+		return ref.Add(time.Duration(n) * time.Hour), used, true
+	case day:
+		return ref.AddDate(0, 0, n), used, true
+	default:
+		return ref.AddDate(0, 0, 7*n), used, true
+	}
+}
+
+// isAfter returns whether word is a Finnish postposition meaning "after"
+func isAfter(word string) bool {
+	return word == "päästä" || word == "kuluttua"
+}
+
+func parseNumber(word string) (int, bool) {
+	if n, ok := numbers[word]; ok {
+		return n, true
+	}
+	n, err := strconv.Atoi(word)
+	return n, err == nil
+}
+
+// parseEndOf parses "end of day", "eod", "end of the week", "päivän
+// lopussa" and friends
+func parseEndOf(tokens []token, ref time.Time) (time.Time, int, bool) {
+	used := 0
+	endOfWeek := false
+	switch {
+	case words(tokens, "eod"):
+		used = 1
+	case words(tokens, "eow"):
+		used, endOfWeek = 1, true
+	case words(tokens, "end", "of", "day"), words(tokens, "end", "of", "today"):
+		used = 3
+	case words(tokens, "end", "of", "the", "day"):
+		used = 4
+	case words(tokens, "end", "of", "week"):
+		used, endOfWeek = 3, true
+	case words(tokens, "end", "of", "the", "week"):
+		used, endOfWeek = 4, true
+	case words(tokens, "päivän", "lopussa"), words(tokens, "päivän", "päätteeksi"):
+		used = 2
+	case words(tokens, "viikon", "lopussa"), words(tokens, "viikon", "päätteeksi"):
+		used, endOfWeek = 2, true
+	default:
+		return time.Time{}, 0, false
+	}
+	t := at(ref, endOfDayHour, 0)
+	if endOfWeek {
+		for t.Weekday() != time.Friday || !t.After(ref) {
+			t = t.AddDate(0, 0, 1)
+		}
+	} else if !t.After(ref) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, used, true
+}
+
+// parseDay parses a day, e.g. "tomorrow", "next tuesday" or "huomenna",
+// optionally followed by a time of day, e.g. "afternoon" or "at 15:30". A
+// day without a time of day is at 09:00.
+func parseDay(tokens []token, ref time.Time) (time.Time, int, bool) {
+	var date time.Time
+	used := 0
+	needsTime := false
+	switch {
+	case words(tokens, "tomorrow"), words(tokens, "huomenna"):
+		date, used = ref.AddDate(0, 0, 1), 1
+	case words(tokens, "day", "after", "tomorrow"):
+		date, used = ref.AddDate(0, 0, 2), 3
+	case words(tokens, "ylihuomenna"):
+		date, used = ref.AddDate(0, 0, 2), 1
+	case words(tokens, "today"), words(tokens, "tänään"), words(tokens, "this"), words(tokens, "tänä"):
+		date, used, needsTime = ref, 1, true
+	case words(tokens, "tonight"):
+		return at(ref, partsOfDay["evening"], 0), 1, true
+	case words(tokens, "next", "week"), words(tokens, "ensi", "viikolla"):
+		date, used = nextWeekday(ref, time.Monday), 2
+	case len(tokens) >= 2 && (words(tokens, "next") || words(tokens, "on") || words(tokens, "ensi")):
+		weekday, ok := weekdays[tokens[1].word]
+		if !ok {
+			return time.Time{}, 0, false
+		}
+		date, used = nextWeekday(ref, weekday), 2
+	case len(tokens) >= 1 && finnishWeekdays[tokens[0].word]:
+		date, used = nextWeekday(ref, weekdays[tokens[0].word]), 1
+	default:
+		return time.Time{}, 0, false
+	}
+
+	hour, min, n, ok := parseTimeOfDay(tokens[used:])
+	if !ok {
+		if needsTime {
+			return time.Time{}, 0, false
+		}
+		return at(date, defaultHour, 0), used, true
+	}
+	return at(date, hour, min), used + n, true
+}
+
+// parseTimeOfDay parses "afternoon", "in the morning", "at 15:30", "at 3pm",
+// "iltapäivällä" or "klo 15" and returns the hour, minute and the number of
+// tokens used
+func parseTimeOfDay(tokens []token) (int, int, int, bool) {
+	skip := 0
+	if words(tokens, "in", "the") {
+		skip = 2
+	}
+	if len(tokens) > skip {
+		if hour, ok := partsOfDay[tokens[skip].word]; ok {
+			return hour, 0, skip + 1, true
+		}
+	}
+	if len(tokens) >= 2 && (words(tokens, "at") || words(tokens, "klo") || words(tokens, "kello")) {
+		if words(tokens[1:], "noon") {
+			return 12, 0, 2, true
+		}
+		hour, min, ok := parseClock(tokens[1].word)
+		if ok {
+			return hour, min, 2, true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// parseClock parses "15", "15:30", "15.30", "3pm" and "10:30am"
+func parseClock(word string) (int, int, bool) {
+	meridiem := strings.HasSuffix(word, "am") || strings.HasSuffix(word, "pm")
+	offset := 0
+	if meridiem {
+		if strings.HasSuffix(word, "pm") {
+			offset = 12
+		}
+		word = word[:len(word)-2]
+	}
+	parts := strings.FieldsFunc(word, func(r rune) bool { return r == ':' || r == '.' })
+	if len(parts) == 0 || len(parts) > 2 {
+		return 0, 0, false
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, 0, false
+	}
+	if meridiem {
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour = hour%12 + offset
+	}
+	min := 0
+	if len(parts) == 2 {
+		min, err = strconv.Atoi(parts[1])
+		if err != nil || min < 0 || min > 59 {
+			return 0, 0, false
+		}
+	}
+	return hour, min, true
+}
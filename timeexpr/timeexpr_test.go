@@ -0,0 +1,105 @@
+package timeexpr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Helsinki")
+	// Wednesday
+	ref := time.Date(2026, 10, 14, 15, 20, 0, 0, location)
+	date := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, location)
+	}
+
+	tests := []struct {
+		text     string
+		want     time.Time
+		consumed string
+	}{
+		{"tomorrow", date(10, 15, 9, 0), "tomorrow"},
+		{" tomorrow afternoon, please check", date(10, 15, 13, 0), " tomorrow afternoon"},
+		{"Tomorrow in the evening", date(10, 15, 18, 0), "Tomorrow in the evening"},
+		{"tomorrow at 15:30 about the PR", date(10, 15, 15, 30), "tomorrow at 15:30"},
+		{"tomorrow at 3pm", date(10, 15, 15, 0), "tomorrow at 3pm"},
+		{"day after tomorrow at noon", date(10, 16, 12, 0), "day after tomorrow at noon"},
+		{"next tuesday", date(10, 20, 9, 0), "next tuesday"},
+		{"on wednesday morning", date(10, 21, 9, 0), "on wednesday morning"},
+		{"next week", date(10, 19, 9, 0), "next week"},
+		{"in 3 days.", date(10, 17, 15, 20), "in 3 days"},
+		{"in an hour", date(10, 14, 16, 20), "in an hour"},
+		{"in 2 weeks", date(10, 28, 15, 20), "in 2 weeks"},
+		{"in 45 minutes", date(10, 14, 16, 5), "in 45 minutes"},
+		{"end of day", date(10, 14, 17, 0), "end of day"},
+		{"EOD!", date(10, 14, 17, 0), "EOD"},
+		{"end of the week", date(10, 16, 17, 0), "end of the week"},
+		{"this evening", date(10, 14, 18, 0), "this evening"},
+		{"tonight", date(10, 14, 18, 0), "tonight"},
+		{"today at 16", date(10, 14, 16, 0), "today at 16"},
+		{"huomenna", date(10, 15, 9, 0), "huomenna"},
+		{"huomenna iltapäivällä", date(10, 15, 13, 0), "huomenna iltapäivällä"},
+		{"huomenna klo 10.30", date(10, 15, 10, 30), "huomenna klo 10.30"},
+		{"ylihuomenna", date(10, 16, 9, 0), "ylihuomenna"},
+		{"ensi tiistaina", date(10, 20, 9, 0), "ensi tiistaina"},
+		{"perjantaina aamulla", date(10, 16, 9, 0), "perjantaina aamulla"},
+		{"keskiviikkona", date(10, 21, 9, 0), "keskiviikkona"},
+		{"3 päivän päästä", date(10, 17, 15, 20), "3 päivän päästä"},
+		{"tunnin kuluttua", date(10, 14, 16, 20), "tunnin kuluttua"},
+		{"päivän lopussa", date(10, 14, 17, 0), "päivän lopussa"},
+		{"tänä iltana", date(10, 14, 18, 0), "tänä iltana"},
+		{"ensi viikolla", date(10, 19, 9, 0), "ensi viikolla"},
+	}
+	for _, test := range tests {
+		got, n, ok := Parse(test.text, ref)
+		if !ok {
+			t.Errorf("Parse(%q): no expression found", test.text)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("Parse(%q): wanted %v, got %v", test.text, test.want, got)
+		}
+		if test.text[:n] != test.consumed {
+			t.Errorf("Parse(%q): wanted to consume %q, consumed %q", test.text, test.consumed, test.text[:n])
+		}
+	}
+}
+
+func TestParseNoExpression(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Helsinki")
+	ref := time.Date(2026, 10, 14, 15, 20, 0, 0, location)
+
+	for _, text := range []string{
+		"",
+		"can you review this",
+		"today is the deadline",
+		"this is important",
+		"monday's meeting notes",
+		"tonight's build",
+		"next week's release notes",
+		"tomorrow’s standup",
+		"in the meeting",
+		"on it",
+		"this morning", // already passed
+		"in 0 days",
+	} {
+		if got, _, ok := Parse(text, ref); ok {
+			t.Errorf("Parse(%q): expected no expression, got %v", text, got)
+		}
+	}
+}
+
+func TestParseEndOfDayAfterWork(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Helsinki")
+	// Friday evening
+	ref := time.Date(2026, 10, 16, 18, 0, 0, 0, location)
+
+	got, _, _ := Parse("end of day", ref)
+	if want := time.Date(2026, 10, 17, 17, 0, 0, 0, location); !got.Equal(want) {
+		t.Errorf("end of day: wanted %v, got %v", want, got)
+	}
+	got, _, _ = Parse("end of week", ref)
+	if want := time.Date(2026, 10, 23, 17, 0, 0, 0, location); !got.Equal(want) {
+		t.Errorf("end of week: wanted %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// deliveryTimeHelp returns a description of the explicit delivery time syntax
+func deliveryTimeHelp() string {
+	return fmt.Sprintf("Pick the delivery time with %[1]s<nick>@14:30 (the next 14:30 in their timezone),"+
+		" %[1]s<nick>+2h (after a duration like 45m, 2h or 1d) or %[1]s<nick>/mon (next monday at 09:00, or /mon@14:30).", slowPrefix)
+}
+
+// parseDeliveryTime parses the explicit delivery time of a ping. The kind is
+// @ for a time of day, + for a duration and / for a weekday. The result is
+// the first matching moment after now in location.
+func parseDeliveryTime(kind, spec string, now time.Time, location *time.Location) (time.Time, error) {
+	spec = strings.ToLower(strings.TrimRight(spec, "."))
+	now = now.In(location)
+	switch kind {
+	case "@":
+		hour, min, err := parseClock(spec)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t := time.Date(now.Year(), now.Month(), now.Day(), hour, min, 0, 0, location)
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	case "+":
+		d, err := parseDuration(spec)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	case "/":
+		hour, min := 9, 0
+		parts := strings.SplitN(spec, "@", 2)
+		weekday, ok := weekdays[parts[0]]
+		if !ok {
+			return time.Time{}, fmt.Errorf("Unknown weekday '%s'", parts[0])
+		}
+		if len(parts) == 2 {
+			var err error
+			hour, min, err = parseClock(parts[1])
+			if err != nil {
+				return time.Time{}, err
+			}
+		}
+		t := time.Date(now.Year(), now.Month(), now.Day(), hour, min, 0, 0, location)
+		for t.Weekday() != weekday || !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Unknown delivery time '%s%s'", kind, spec)
+}
+
+// parseClock parses a time of day given as HH:MM, HH.MM or HH
+func parseClock(spec string) (int, int, error) {
+	parts := strings.FieldsFunc(spec, func(r rune) bool { return r == ':' || r == '.' })
+	if len(parts) == 0 || len(parts) > 2 {
+		return 0, 0, fmt.Errorf("Invalid time of day '%s'", spec)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, 0, fmt.Errorf("Invalid time of day '%s'", spec)
+	}
+	min := 0
+	if len(parts) == 2 {
+		min, err = strconv.Atoi(parts[1])
+		if err != nil || min < 0 || min > 59 {
+			return 0, 0, fmt.Errorf("Invalid time of day '%s'", spec)
+		}
+	}
+	return hour, min, nil
+}
+
+// parseDuration parses a positive duration like time.ParseDuration, but also
+// accepts a leading number of days, e.g. 1d or 2d12h
+func parseDuration(spec string) (time.Duration, error) {
+	orig := spec
+	var days time.Duration
+	if i := strings.Index(spec, "d"); i > 0 {
+		n, err := strconv.Atoi(spec[:i])
+		if err != nil {
+			return 0, fmt.Errorf("Invalid duration '%s'", orig)
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		spec = spec[i+1:]
+	}
+	var d time.Duration
+	if spec != "" {
+		var err error
+		d, err = time.ParseDuration(spec)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid duration '%s'", orig)
+		}
+	}
+	if days+d <= 0 {
+		return 0, fmt.Errorf("Invalid duration '%s', it must be positive", orig)
+	}
+	return days + d, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDeliveryTime(t *testing.T) {
+	location, _ := time.LoadLocation("America/New_York")
+	// Wednesday
+	now := time.Date(2026, 10, 14, 15, 0, 0, 0, location)
+
+	tests := []struct {
+		kind string
+		spec string
+		want time.Time
+	}{
+		{"@", "16:30", time.Date(2026, 10, 14, 16, 30, 0, 0, location)},
+		{"@", "14:30", time.Date(2026, 10, 15, 14, 30, 0, 0, location)},
+		{"@", "9", time.Date(2026, 10, 15, 9, 0, 0, 0, location)},
+		{"@", "15.00.", time.Date(2026, 10, 15, 15, 0, 0, 0, location)},
+		{"+", "2h", time.Date(2026, 10, 14, 17, 0, 0, 0, location)},
+		{"+", "1h30m", time.Date(2026, 10, 14, 16, 30, 0, 0, location)},
+		{"+", "1d", time.Date(2026, 10, 15, 15, 0, 0, 0, location)},
+		{"+", "2d12h", time.Date(2026, 10, 17, 3, 0, 0, 0, location)},
+		{"/", "mon", time.Date(2026, 10, 19, 9, 0, 0, 0, location)},
+		{"/", "Friday", time.Date(2026, 10, 16, 9, 0, 0, 0, location)},
+		{"/", "wed", time.Date(2026, 10, 21, 9, 0, 0, 0, location)},
+		{"/", "wed@16", time.Date(2026, 10, 14, 16, 0, 0, 0, location)},
+		{"/", "tue@14:30", time.Date(2026, 10, 20, 14, 30, 0, 0, location)},
+	}
+	for _, test := range tests {
+		got, err := parseDeliveryTime(test.kind, test.spec, now, location)
+		if err != nil {
+			t.Errorf("parseDeliveryTime(%s, %s): unexpected error %v", test.kind, test.spec, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("parseDeliveryTime(%s, %s): wanted %v, got %v", test.kind, test.spec, test.want, got)
+		}
+	}
+
+	for _, invalid := range [][2]string{{"@", "25:00"}, {"@", "noon"}, {"@", ""}, {"+", "soon"}, {"+", "-1h"}, {"+", "0d"}, {"/", "someday"}, {"/", "mon@"}} {
+		_, err := parseDeliveryTime(invalid[0], invalid[1], now, location)
+		if err == nil {
+			t.Errorf("parseDeliveryTime(%s, %s): expected an error", invalid[0], invalid[1])
+		}
+	}
+}
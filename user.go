@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"encoding/gob"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"strings"
+	"time"
 )
 
 // Users is a map of user nickames and IDs
@@ -21,6 +26,16 @@ func (u Users) Exists(nick string) bool {
 	return false
 }
 
+// Nick returns the (lower cased) nick of the user with id
+func (u Users) Nick(id string) string {
+	for nick, userID := range u {
+		if userID == id {
+			return nick
+		}
+	}
+	return ""
+}
+
 // Add adds user with nick (lower cased) to users
 func (u Users) Add(nick, id string) {
 	u[strings.ToLower(nick)] = id
@@ -32,3 +47,59 @@ func (u Users) Print() {
 		fmt.Printf("%s:%s\n", nick, id)
 	}
 }
+
+// UserSetting holds the preferences a user has set for themselves
+type UserSetting struct {
+	Timezone  string
+	AwayUntil time.Time
+}
+
+// UserSettings is a map of user settings by (lower cased) nick
+type UserSettings map[string]UserSetting
+
+// NewUserSettings returns an empty map of user settings
+func NewUserSettings() UserSettings {
+	return make(map[string]UserSetting)
+}
+
+// Get returns the settings of the user with nick
+func (s UserSettings) Get(nick string) UserSetting {
+	return s[strings.ToLower(nick)]
+}
+
+// Set stores the settings of the user with nick
+func (s UserSettings) Set(nick string, setting UserSetting) {
+	s[strings.ToLower(nick)] = setting
+}
+
+// Restore restores saved user settings from file
+func (s UserSettings) Restore(file string) (int, error) {
+	if _, err := os.Stat(file); err == nil {
+		rawData, err := ioutil.ReadFile(file)
+		if err != nil {
+			return 0, fmt.Errorf("Error could not restore user settings because could not read file :-(")
+		}
+		buffer := bytes.NewBuffer(rawData)
+		dec := gob.NewDecoder(buffer)
+
+		err = dec.Decode(&s)
+		if err != nil {
+			return 0, fmt.Errorf("Error could not decode %v", dec)
+		}
+		return len(s), nil
+	}
+	return 0, nil
+}
+
+// Save saves user settings to file
+func (s UserSettings) Save(file string) error {
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	err := enc.Encode(s)
+	if err != nil {
+		return fmt.Errorf("Error could not save the user settings")
+	}
+
+	ioutil.WriteFile(file, buffer.Bytes(), 0600)
+	return nil
+}